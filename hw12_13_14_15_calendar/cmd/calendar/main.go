@@ -4,84 +4,145 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/lifecycle"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/migrator"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/reload"
 	internalgrpc "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/http"
 	initstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/init"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-var configFile string
+var (
+	configFile string
+	flags      config.Flags
+)
 
 func init() {
 	flag.StringVar(&configFile, "config", "/etc/calendar/config.yaml", "Path to configuration file")
+	flags = config.BindFlags(flag.CommandLine)
 }
 
 func main() {
 	flag.Parse()
 
-	if flag.Arg(0) == "version" {
+	switch flag.Arg(0) {
+	case "version":
 		printVersion()
 		return
+	case "export", "import":
+		os.Exit(runTransfer(flag.Arg(0), flag.Args()[1:]))
+	}
+
+	os.Exit(run())
+}
+
+func run() int {
+	rules := []config.Rule{config.Storage, config.Servers}
+	cfg, err := config.Load(configFile, flags, rules...)
+	if err != nil {
+		log.Println(err)
+
+		return lifecycle.ExitFailure
 	}
 
-	cfg, err := config.Parse(configFile)
+	logg := logger.New(cfg.Logger.Level)
+
+	shutdownTracing, err := tracing.Init("calendar", cfg.Tracing)
 	if err != nil {
-		log.Fatal(err)
+		logg.Error("failed to init tracing: " + err.Error())
+
+		return lifecycle.ExitFailure
 	}
 
 	storage, err := initstorage.NewStorage(cfg)
 	if err != nil {
-		log.Fatal(err)
+		logg.Error(err)
+
+		return lifecycle.ExitFailure
 	}
 
-	ctx := context.Background()
-	err = storage.Connect(ctx)
+	verifier, err := auth.New(cfg.Auth)
 	if err != nil {
-		log.Fatalf("failed to load driver: %v", err)
+		logg.Error("failed to init auth: " + err.Error())
+
+		return lifecycle.ExitFailure
 	}
 
-	logg := logger.New(cfg.Logger.Level)
 	calendar := app.New(storage, cfg)
-	server := internalhttp.NewServer(logg, calendar, cfg)
+	checker := health.NewChecker()
+	checker.Add("storage", storage.Ping)
 
-	ctx, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-	defer cancel()
+	server := internalhttp.NewServer(logg, calendar, verifier, checker, cfg)
+	GRPCServer := internalgrpc.NewGRPCServer(logg, calendar, verifier, checker, cfg)
 
-	go func() {
-		<-ctx.Done()
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-		defer cancel()
+	// The certificates are re-read on every SIGHUP, the files may be renewed in place.
+	watcher := reload.New(logg, cfg, func() (*config.Config, error) {
+		return config.Load(configFile, flags, rules...)
+	}, func(next *config.Config) {
+		if err := logg.SetLevel(next.Logger.Level); err != nil {
+			logg.Error(err)
+		}
+		server.SetLimits(next.Limits)
+		GRPCServer.SetLimits(next.Limits)
 
-		if err := server.Stop(ctx); err != nil {
-			logg.Error("failed to stop http server: " + err.Error())
+		if err := server.ReloadTLS(); err != nil {
+			logg.Error("failed to reload http certificates: " + err.Error())
+		}
+		if err := GRPCServer.ReloadTLS(); err != nil {
+			logg.Error("failed to reload grpc certificates: " + err.Error())
 		}
-	}()
+	}, "logger.level", "limits.rps", "limits.burst")
+
+	manager := lifecycle.New(logg, cfg.Shutdown.Timeout)
+	manager.Add(lifecycle.Component{Name: "tracing", Stop: shutdownTracing})
+
+	if cfg.DB.Type == "sql" && cfg.DB.SQL.AutoMigrate {
+		manager.Add(lifecycle.Component{
+			Name: "migrations",
+			Start: func(context.Context) error {
+				return migrator.Migrate(cfg.DB.SQL, initstorage.GetDsn(cfg.DB.SQL))
+			},
+		})
+	}
 
-	logg.Info("calendar is running...")
+	manager.Add(
+		lifecycle.Component{
+			Name:  "storage",
+			Start: storage.Connect,
+			Stop:  func(context.Context) error { return storage.Close() },
+		},
+		lifecycle.Component{Name: "http server", Start: server.Start, Run: server.Serve, Stop: server.Stop},
+		lifecycle.Component{Name: "grpc server", Start: GRPCServer.Start, Run: GRPCServer.Serve, Stop: GRPCServer.Stop},
+		lifecycle.Component{
+			Name: "metrics server",
+			Run:  func(ctx context.Context) error { return metrics.Serve(ctx, cfg.Metrics) },
+		},
+		lifecycle.Component{Name: "config reload", Run: watcher.Run},
+	)
 
-	go func() {
-		if err := server.Start(ctx); err != nil {
-			logg.Error("failed to start http server: " + err.Error())
-			cancel()
-		}
-	}()
+	logg.Info("calendar is running...")
 
-	GRPCServer := internalgrpc.NewGRPCServer(logg, calendar, cfg)
+	if err := manager.Run(ctx); err != nil {
+		logg.Error(err)
 
-	go func() {
-		if err := GRPCServer.Start(ctx); err != nil {
-			logg.Error("failed to start grpc server: " + err.Error())
-			cancel()
-		}
-	}()
+		return lifecycle.ExitCode(err)
+	}
 
-	<-ctx.Done()
+	return lifecycle.ExitOK
 }
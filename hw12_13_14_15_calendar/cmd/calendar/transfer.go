@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/lifecycle"
+	initstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/init"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/transfer"
+	"github.com/google/uuid"
+)
+
+// runTransfer runs the export or import command: calendar [flags] export|import [command flags].
+func runTransfer(command string, args []string) int {
+	cfg, err := config.Load(configFile, flags, config.Storage)
+	if err != nil {
+		log.Println(err)
+
+		return lifecycle.ExitFailure
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	s, err := initstorage.NewTransferStorage(cfg)
+	if err != nil {
+		log.Println(err)
+
+		return lifecycle.ExitFailure
+	}
+
+	if err := s.Connect(ctx); err != nil {
+		log.Println(err)
+
+		return lifecycle.ExitFailure
+	}
+	defer s.Close()
+
+	if command == "export" {
+		err = export(ctx, s, args)
+	} else {
+		err = load(ctx, s, args)
+	}
+
+	if err != nil {
+		log.Println(err)
+
+		return lifecycle.ExitFailure
+	}
+
+	return lifecycle.ExitOK
+}
+
+func export(ctx context.Context, s transfer.Storage, args []string) (err error) {
+	set := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := set.String("out", "-", "File to write the events to, - for stdout")
+	user := set.String("user", "", "Export the events of the user only")
+	from := set.String("from", "", "Export the events ending after the time, RFC 3339")
+	to := set.String("to", "", "Export the events starting before the time, RFC 3339")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	p := transfer.AllTime
+	if *user != "" {
+		if p.UserID, err = uuid.Parse(*user); err != nil {
+			return fmt.Errorf("bad user: %w", err)
+		}
+	}
+	if *from != "" {
+		if p.Start, err = time.Parse(time.RFC3339, *from); err != nil {
+			return fmt.Errorf("bad from: %w", err)
+		}
+	}
+	if *to != "" {
+		if p.End, err = time.Parse(time.RFC3339, *to); err != nil {
+			return fmt.Errorf("bad to: %w", err)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+		w = f
+	}
+
+	buffered := bufio.NewWriter(w)
+
+	exported, err := transfer.Export(ctx, s, p, buffered)
+	if err != nil {
+		return err
+	}
+
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+
+	log.Printf("exported %d events", exported)
+
+	return nil
+}
+
+func load(ctx context.Context, s transfer.Storage, args []string) error {
+	set := flag.NewFlagSet("import", flag.ContinueOnError)
+	in := set.String("in", "-", "File to read the events from, - for stdin")
+	conflict := set.String("conflict", string(transfer.Fail), "Policy for the taken IDs: skip, overwrite or fail")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	policy, err := transfer.ParseConflict(*conflict)
+	if err != nil {
+		return err
+	}
+
+	r := io.Reader(os.Stdin)
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	result, err := transfer.Import(ctx, s, bufio.NewReader(r), policy)
+	log.Printf("added %d, overwritten %d, skipped %d events", result.Added, result.Overwritten, result.Skipped)
+
+	return err
+}
@@ -4,54 +4,114 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/lifecycle"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
 	rmqqueue "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/queue/rmq"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/reload"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
 	initstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/init"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-var configFile string
+var (
+	configFile string
+	flags      config.Flags
+)
 
 func init() {
 	flag.StringVar(&configFile, "config", "/etc/calendar/config-scheduler.yaml", "Path to configuration file")
+	flags = config.BindFlags(flag.CommandLine)
 }
 
 func main() {
 	flag.Parse()
 
-	cfg, err := config.Parse(configFile)
+	os.Exit(run())
+}
+
+func run() int {
+	rules := []config.Rule{config.Storage, config.Queue, config.Schedule}
+	cfg, err := config.Load(configFile, flags, rules...)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
+
+		return lifecycle.ExitFailure
 	}
 
-	storage, err := initstorage.NewSchedulerStorage(cfg)
+	logg := logger.New(cfg.Logger.Level)
+
+	shutdownTracing, err := tracing.Init("calendar_scheduler", cfg.Tracing)
 	if err != nil {
-		log.Fatal(err)
+		logg.Error("failed to init tracing: " + err.Error())
+
+		return lifecycle.ExitFailure
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-	err = storage.Connect(ctx)
+	storage, err := initstorage.NewSchedulerStorage(cfg)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer cancel()
+		logg.Error(err)
 
-	logg := logger.New(cfg.Logger.Level)
+		return lifecycle.ExitFailure
+	}
 
 	rmq := rmqqueue.NewRmq(cfg)
-	sch := scheduler.NewScheduler(rmq, *logg, storage, cfg.QueueName)
+	sch := scheduler.NewScheduler(rmq, *logg, storage, cfg.QueueName, cfg.Scheduler)
+
+	checker := health.NewChecker()
+	checker.Add("storage", storage.Ping)
+	checker.Add("queue", rmq.Ping)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
 
-	go func() {
-		if err := sch.Run(ctx); err != nil {
-			log.Fatal(err)
+	watcher := reload.New(logg, cfg, func() (*config.Config, error) {
+		return config.Load(configFile, flags, rules...)
+	}, func(next *config.Config) {
+		if err := logg.SetLevel(next.Logger.Level); err != nil {
+			logg.Error(err)
 		}
-	}()
+		sch.SetConfig(next.Scheduler)
+	}, "logger.level", "scheduler")
+
+	manager := lifecycle.New(logg, cfg.Shutdown.Timeout)
+	manager.Add(
+		lifecycle.Component{Name: "tracing", Stop: shutdownTracing},
+		lifecycle.Component{
+			Name:  "storage",
+			Start: storage.Connect,
+			Stop:  func(context.Context) error { return storage.Close() },
+		},
+		lifecycle.Component{
+			Name:  "queue",
+			Start: rmq.Connect,
+			Stop:  func(context.Context) error { return rmq.Close() },
+		},
+		lifecycle.Component{
+			Name: "metrics server",
+			Run:  func(ctx context.Context) error { return metrics.Serve(ctx, cfg.Metrics) },
+		},
+		lifecycle.Component{
+			Name: "health server",
+			Run:  func(ctx context.Context) error { return health.Serve(ctx, cfg.Health, checker) },
+		},
+		lifecycle.Component{Name: "scheduler", Run: sch.Run},
+		lifecycle.Component{Name: "config reload", Run: watcher.Run},
+	)
+
+	if err := manager.Run(ctx); err != nil {
+		logg.Error(err)
+
+		return lifecycle.ExitCode(err)
+	}
 
-	log.Println("Logger started...")
-	<-ctx.Done()
+	return lifecycle.ExitOK
 }
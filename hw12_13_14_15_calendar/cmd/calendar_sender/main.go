@@ -4,43 +4,110 @@ import (
 	"context"
 	"flag"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/consumer/sender"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/lifecycle"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/notify"
 	rmqqueue "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/queue/rmq"
-	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/reload"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 )
 
-var configFile string
+var (
+	configFile string
+	flags      config.Flags
+)
 
 func init() {
 	flag.StringVar(&configFile, "config", "/etc/calendar/config-sender.yaml", "Path to configuration file")
+	flags = config.BindFlags(flag.CommandLine)
 }
 
 func main() {
 	flag.Parse()
 
-	cfg, err := config.Parse(configFile)
+	os.Exit(run())
+}
+
+func run() int {
+	rules := []config.Rule{config.Queue, config.Notifications}
+	cfg, err := config.Load(configFile, flags, rules...)
 	if err != nil {
-		log.Fatal(err)
-	}
+		log.Println(err)
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
-	defer cancel()
+		return lifecycle.ExitFailure
+	}
 
-	rmq := rmqqueue.NewRmq(cfg)
 	logg := logger.New(cfg.Logger.Level)
 
+	shutdownTracing, err := tracing.Init("calendar_sender", cfg.Tracing)
+	if err != nil {
+		logg.Error("failed to init tracing: " + err.Error())
+
+		return lifecycle.ExitFailure
+	}
+
+	rmq := rmqqueue.NewRmq(cfg)
 	sdr := sender.NewSender(rmq, *logg, cfg.QueueName)
-	go func() {
-		if err := sdr.Run(ctx); err != nil {
-			log.Fatal(err)
+	if err := sdr.SetTemplate(cfg.Sender.Template); err != nil {
+		logg.Error(err)
+
+		return lifecycle.ExitFailure
+	}
+	sdr.SetRouter(notify.NewRouter(cfg.Sender, logg))
+	sdr.SetRetries(cfg.Sender.Retries, cfg.Sender.RetryBackoff)
+
+	checker := health.NewChecker()
+	checker.Add("queue", rmq.Ping)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	watcher := reload.New(logg, cfg, func() (*config.Config, error) {
+		return config.Load(configFile, flags, rules...)
+	}, func(next *config.Config) {
+		if err := logg.SetLevel(next.Logger.Level); err != nil {
+			logg.Error(err)
 		}
-	}()
+		if err := sdr.SetTemplate(next.Sender.Template); err != nil {
+			logg.Error(err)
+		}
+		sdr.SetRouter(notify.NewRouter(next.Sender, logg))
+		sdr.SetRetries(next.Sender.Retries, next.Sender.RetryBackoff)
+	}, "logger.level", "sender")
+
+	manager := lifecycle.New(logg, cfg.Shutdown.Timeout)
+	manager.Add(
+		lifecycle.Component{Name: "tracing", Stop: shutdownTracing},
+		lifecycle.Component{
+			Name:  "queue",
+			Start: rmq.Connect,
+			Stop:  func(context.Context) error { return rmq.Close() },
+		},
+		lifecycle.Component{
+			Name: "metrics server",
+			Run:  func(ctx context.Context) error { return metrics.Serve(ctx, cfg.Metrics) },
+		},
+		lifecycle.Component{
+			Name: "health server",
+			Run:  func(ctx context.Context) error { return health.Serve(ctx, cfg.Health, checker) },
+		},
+		lifecycle.Component{Name: "sender", Run: sdr.Run},
+		lifecycle.Component{Name: "config reload", Run: watcher.Run},
+	)
+
+	if err := manager.Run(ctx); err != nil {
+		logg.Error(err)
+
+		return lifecycle.ExitCode(err)
+	}
 
-	log.Println("Sender started...")
-	<-ctx.Done()
+	return lifecycle.ExitOK
 }
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	internalgrpc "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+var errNoID = errors.New("the event id is required")
+
+type command struct {
+	client internalgrpc.EventServiceClient
+	out    io.Writer
+	format format
+}
+
+// save creates or updates the events of the -f file, or the event of the flags when there is no file.
+func (c *command) save(ctx context.Context, args []string, update bool) error {
+	name := "create"
+	call := c.client.Create
+	if update {
+		name, call = "update", c.client.Update
+	}
+
+	set := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := set.String("f", "", "YAML or JSON file of the events, - for stdin")
+
+	var e event
+	set.StringVar(&e.ID, "id", "", "Event id, a new one on create when empty")
+	set.StringVar(&e.Title, "title", "", "Title")
+	set.StringVar(&e.DatetimeStart, "start", "", "Start time, RFC 3339")
+	set.StringVar(&e.DatetimeEnd, "end", "", "End time, RFC 3339")
+	set.StringVar(&e.Description, "description", "", "Description")
+	set.StringVar(&e.UserID, "user", "", "Owner id")
+	set.StringVar(&e.WhenToNotify, "notify", "", "Reminder time, RFC 3339, the start when empty")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	events := []event{e}
+	if *file != "" {
+		var err error
+		if events, err = readFile(*file); err != nil {
+			return err
+		}
+	}
+
+	requests := make([]*internalgrpc.Event, 0, len(events))
+	for i := range events {
+		if events[i].ID == "" {
+			if update {
+				return fmt.Errorf("event %q: %w", events[i].Title, errNoID)
+			}
+			events[i].ID = uuid.New().String()
+		}
+
+		request, err := events[i].toProto()
+		if err != nil {
+			return err
+		}
+		requests = append(requests, request)
+	}
+
+	saved := make([]event, 0, len(requests))
+	for _, request := range requests {
+		if _, err := call(ctx, request); err != nil {
+			// The events saved before the failure stay, they are reported to not be sent twice.
+			if len(saved) > 0 {
+				_ = printEvents(c.out, c.format, saved)
+			}
+
+			return fmt.Errorf("%s event %s: %w", name, request.Id, err)
+		}
+		saved = append(saved, fromProto(request))
+	}
+
+	return printEvents(c.out, c.format, saved)
+}
+
+func readFile(name string) ([]event, error) {
+	if name == "-" {
+		return readEvents(os.Stdin)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readEvents(f)
+}
+
+func (c *command) delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return errNoID
+	}
+
+	for _, id := range ids {
+		if _, err := c.client.Delete(ctx, &internalgrpc.DeleteRequest{Id: id}); err != nil {
+			return fmt.Errorf("delete event %s: %w", id, err)
+		}
+		fmt.Fprintln(c.out, "deleted", id)
+	}
+
+	return nil
+}
+
+func (c *command) get(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return errNoID
+	}
+
+	events := make([]event, 0, len(ids))
+	for _, id := range ids {
+		e, err := c.client.Get(ctx, &internalgrpc.GetRequest{Id: id})
+		if err != nil {
+			return fmt.Errorf("get event %s: %w", id, err)
+		}
+		events = append(events, fromProto(e))
+	}
+
+	return printEvents(c.out, c.format, events)
+}
+
+func (c *command) list(ctx context.Context, args []string) error {
+	set := flag.NewFlagSet("list", flag.ContinueOnError)
+	period := set.String("period", "day", "Period to list: day, week or month")
+	date := set.String("date", time.Now().Format("2006-01-02"), "First day of the period, YYYY-MM-DD")
+	if err := set.Parse(args); err != nil {
+		return err
+	}
+
+	var call func(context.Context, *internalgrpc.DateRequest, ...grpc.CallOption) (*internalgrpc.EventsResponse, error)
+
+	switch *period {
+	case "day":
+		call = c.client.EventListOfDay
+	case "week":
+		call = c.client.EventListOfWeek
+	case "month":
+		call = c.client.EventListOfMonth
+	default:
+		return fmt.Errorf(`the period must be "day", "week" or "month", got %q`, *period)
+	}
+
+	if _, err := time.Parse("2006-01-02", *date); err != nil {
+		return fmt.Errorf("bad date: %w", err)
+	}
+
+	resp, err := call(ctx, &internalgrpc.DateRequest{Date: *date})
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+
+	events := make([]event, 0, len(resp.Events))
+	for _, e := range resp.Events {
+		events = append(events, fromProto(e))
+	}
+	sortEvents(events)
+
+	return printEvents(c.out, c.format, events)
+}
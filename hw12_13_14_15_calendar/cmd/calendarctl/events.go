@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	internalgrpc "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
+	"gopkg.in/yaml.v3"
+)
+
+var errNoEvents = errors.New("no events in the input")
+
+// event is an event of the files and the json and yaml output.
+type event struct {
+	ID            string `json:"id" yaml:"id"`
+	Title         string `json:"title" yaml:"title"`
+	DatetimeStart string `json:"datetimeStart" yaml:"datetimeStart"`
+	DatetimeEnd   string `json:"datetimeEnd" yaml:"datetimeEnd"`
+	Description   string `json:"description,omitempty" yaml:"description,omitempty"`
+	UserID        string `json:"userId" yaml:"userId"`
+	WhenToNotify  string `json:"whenToNotify,omitempty" yaml:"whenToNotify,omitempty"`
+}
+
+func fromProto(e *internalgrpc.Event) event {
+	return event{
+		ID:            e.Id,
+		Title:         e.Title,
+		DatetimeStart: e.DatetimeStart,
+		DatetimeEnd:   e.DatetimeEnd,
+		Description:   e.Description,
+		UserID:        e.UserId,
+		WhenToNotify:  e.WhenToNotify,
+	}
+}
+
+// toProto checks the times, so a bad file fails before any of its events is sent. An event without
+// a reminder time is reminded of at its start.
+func (e event) toProto() (*internalgrpc.Event, error) {
+	when := e.WhenToNotify
+	if when == "" {
+		when = e.DatetimeStart
+	}
+
+	times := []struct{ name, value string }{{"start", e.DatetimeStart}, {"end", e.DatetimeEnd}, {"when", when}}
+	for _, t := range times {
+		if _, err := time.Parse(time.RFC3339, t.value); err != nil {
+			return nil, fmt.Errorf("event %q: bad %s time, RFC 3339 expected: %w", e.Title, t.name, err)
+		}
+	}
+
+	return &internalgrpc.Event{
+		Id:            e.ID,
+		Title:         e.Title,
+		DatetimeStart: e.DatetimeStart,
+		DatetimeEnd:   e.DatetimeEnd,
+		Description:   e.Description,
+		UserId:        e.UserID,
+		WhenToNotify:  when,
+	}, nil
+}
+
+// readEvents reads the YAML or JSON documents of r, a document is an event or a list of events.
+func readEvents(r io.Reader) ([]event, error) {
+	var events []event
+
+	decoder := yaml.NewDecoder(r)
+
+	for {
+		var doc yaml.Node
+
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read events: %w", err)
+		}
+
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode {
+			var list []event
+			if err := doc.Decode(&list); err != nil {
+				return nil, fmt.Errorf("read events: %w", err)
+			}
+			events = append(events, list...)
+
+			continue
+		}
+
+		var e event
+		if err := doc.Decode(&e); err != nil {
+			return nil, fmt.Errorf("read events: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) == 0 {
+		return nil, errNoEvents
+	}
+
+	return events, nil
+}
+
+// sortEvents orders the events by the start, the server lists them in no particular order.
+func sortEvents(events []event) {
+	start := func(e event) time.Time {
+		t, _ := time.Parse(time.RFC3339, e.DatetimeStart)
+
+		return t
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return start(events[i]).Before(start(events[j]))
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEvents(t *testing.T) {
+	input := `
+- title: first
+  datetimeStart: "2026-10-19T10:00:00Z"
+  datetimeEnd: "2026-10-19T11:00:00Z"
+- title: second
+---
+{"id": "4559a809-84a9-4393-b57c-94c2227206db", "title": "third", "whenToNotify": "2026-10-19T09:00:00Z"}
+`
+
+	events, err := readEvents(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, "first", events[0].Title)
+	require.Equal(t, "2026-10-19T11:00:00Z", events[0].DatetimeEnd)
+	require.Equal(t, "second", events[1].Title)
+	require.Equal(t, "4559a809-84a9-4393-b57c-94c2227206db", events[2].ID)
+	require.Equal(t, "2026-10-19T09:00:00Z", events[2].WhenToNotify)
+
+	_, err = readEvents(strings.NewReader(""))
+	require.ErrorIs(t, err, errNoEvents)
+}
+
+func TestToProto(t *testing.T) {
+	e := event{Title: "a", DatetimeStart: "2026-10-19T10:00:00Z", DatetimeEnd: "2026-10-19T11:00:00Z"}
+
+	request, err := e.toProto()
+	require.NoError(t, err)
+	require.Equal(t, e.DatetimeStart, request.WhenToNotify)
+
+	e.DatetimeEnd = "tomorrow"
+	_, err = e.toProto()
+	require.EqualError(t, err, `event "a": bad end time, RFC 3339 expected: `+
+		`parsing time "tomorrow" as "2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`)
+}
+
+func TestPrintEvents(t *testing.T) {
+	var out bytes.Buffer
+
+	require.NoError(t, printEvents(&out, jsonList, nil))
+	require.Equal(t, "[]\n", out.String())
+
+	out.Reset()
+	require.NoError(t, printEvents(&out, table, []event{{ID: "1", Title: "standup", UserID: "2"}}))
+	require.Equal(t, "ID  TITLE    START  END  NOTIFY  USER\n1   standup                      2\n", out.String())
+}
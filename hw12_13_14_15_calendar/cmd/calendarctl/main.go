@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	internalgrpc "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+const usage = `Usage: calendarctl [flags] command [command flags]
+
+Commands:
+  create [-f file] [event flags]   create the events of the file, or the event of the flags
+  update [-f file] [event flags]   replace the events of the file, or the event of the flags
+  delete <id>...                   delete the events
+  get <id>...                      print the events
+  list [-period p] [-date d]       print the events of the day, week or month starting at the date
+
+The files hold an event or a list of events in YAML or JSON, the keys are those of the -o json output.
+Every flag falls back to an environment variable, CALENDARCTL_SERVER_NAME for -server-name and so on.
+
+Flags:
+`
+
+var (
+	addr    string
+	output  string
+	token   string
+	apiKey  string
+	timeout time.Duration
+	tlsCfg  config.ClientTLS
+
+	errUsage = errors.New("bad command, see -help")
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", env("ADDR", "localhost:8081"), "Address of the calendar gRPC server")
+	flag.StringVar(&output, "output", env("OUTPUT", string(table)), "Output format: table, json or yaml")
+	flag.StringVar(&output, "o", env("OUTPUT", string(table)), "Shorthand for -output")
+	flag.StringVar(&token, "token", env("TOKEN", ""), "Bearer token of the user")
+	flag.StringVar(&apiKey, "api-key", env("API_KEY", ""), "API key of the user")
+	flag.DurationVar(&timeout, "timeout", envDuration("TIMEOUT", 10*time.Second), "Timeout of a command")
+	flag.BoolVar(&tlsCfg.Enabled, "tls", envBool("TLS", false), "Connect with TLS")
+	flag.StringVar(&tlsCfg.CAFile, "ca", env("CA", ""), "CA certificate of the server, the system pool when empty")
+	flag.StringVar(&tlsCfg.CertFile, "cert", env("CERT", ""), "Client certificate for mutual TLS")
+	flag.StringVar(&tlsCfg.KeyFile, "key", env("KEY", ""), "Client key for mutual TLS")
+	flag.StringVar(&tlsCfg.ServerName, "server-name", env("SERVER_NAME", ""), "Server name to verify, the host by default")
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+}
+
+func env(name, fallback string) string {
+	if value, ok := os.LookupEnv("CALENDARCTL_" + name); ok {
+		return value
+	}
+
+	return fallback
+}
+
+func envBool(name string, fallback bool) bool {
+	value, err := strconv.ParseBool(env(name, strconv.FormatBool(fallback)))
+	if err != nil {
+		log.Fatalf("bad CALENDARCTL_%s: %s", name, err)
+	}
+
+	return value
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(env(name, fallback.String()))
+	if err != nil {
+		log.Fatalf("bad CALENDARCTL_%s: %s", name, err)
+	}
+
+	return value
+}
+
+func main() {
+	flag.Parse()
+
+	if err := run(flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	format, err := parseFormat(output)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(withCredentials(context.Background()), timeout)
+	defer cancel()
+
+	c := &command{client: internalgrpc.NewEventServiceClient(conn), out: os.Stdout, format: format}
+
+	switch args[0] {
+	case "create":
+		return c.save(ctx, args[1:], false)
+	case "update":
+		return c.save(ctx, args[1:], true)
+	case "delete":
+		return c.delete(ctx, args[1:])
+	case "get":
+		return c.get(ctx, args[1:])
+	case "list":
+		return c.list(ctx, args[1:])
+	}
+
+	return errUsage
+}
+
+func dial() (*grpc.ClientConn, error) {
+	creds := grpc.WithInsecure()
+
+	if tlsCfg.Enabled {
+		cfg, err := tlsconfig.ClientConfig(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(cfg))
+	}
+
+	conn, err := grpc.Dial(addr, creds)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+
+	return conn, nil
+}
+
+// withCredentials adds the metadata the server authenticates the calls with.
+func withCredentials(ctx context.Context) context.Context {
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	if apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
+	}
+
+	return ctx
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+type format string
+
+const (
+	table    format = "table"
+	jsonList format = "json"
+	yamlList format = "yaml"
+)
+
+var errBadFormat = errors.New(`the output format must be "table", "json" or "yaml"`)
+
+func parseFormat(name string) (format, error) {
+	switch f := format(name); f {
+	case table, jsonList, yamlList:
+		return f, nil
+	}
+
+	return "", errBadFormat
+}
+
+// printEvents writes the events as a table or as a JSON or YAML list, an empty list for no events.
+func printEvents(w io.Writer, f format, events []event) error {
+	if events == nil {
+		events = []event{}
+	}
+
+	switch f {
+	case jsonList:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(events)
+	case yamlList:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(events); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	case table:
+		return printTable(w, events)
+	}
+
+	return errBadFormat
+}
+
+func printTable(w io.Writer, events []event) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tTITLE\tSTART\tEND\tNOTIFY\tUSER")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.ID, e.Title, e.DatetimeStart, e.DatetimeEnd, e.WhenToNotify, e.UserID)
+	}
+
+	return tw.Flush()
+}
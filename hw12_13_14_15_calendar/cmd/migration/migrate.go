@@ -1,30 +1,56 @@
 package main
 
 import (
-	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
-	migrate "github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/pgx"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/migrator"
+	initstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/init"
+	sqlstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/sql"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-var configFile string
+const usage = `Usage: migration [flags] [command]
+
+Commands:
+  up [n]           apply n or all pending migrations, the default command
+  down [n]         revert n applied migrations, 1 by default
+  goto <version>   migrate up or down to the version
+  status           print the database version and the migrations
+  force <version>  set the version after a failed migration without running it, -1 for none
+  create <name>    add an empty migration of the dialect to -dir
+
+Flags:
+`
+
+var (
+	configFile string
+	dir        string
+	flags      config.Flags
+
+	errUsage = errors.New("bad command, see -help")
+)
 
 func init() {
 	flag.StringVar(&configFile, "config", "/etc/calendar/config.yaml", "Path to configuration file")
+	flag.StringVar(&dir, "dir", "", "Directory of the created migrations, migrations/<dialect> when empty")
+	flags = config.BindFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
 	flag.Parse()
 
-	cfg, err := config.Parse(configFile)
+	cfg, err := config.Load(configFile, flags, config.Storage)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -33,32 +59,128 @@ func main() {
 		log.Fatal("no sql database type selected")
 	}
 
-	s := cfg.DB.SQL
+	if err := run(cfg, flag.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", s.User, s.Password, s.Host, s.Port, s.Name)
+func run(cfg *config.Config, args []string) error {
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
 
-	db, err := sql.Open(cfg.DB.SQL.Driver, dsn)
-	if err != nil {
-		log.Fatal(err)
+	if command == "create" {
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		if dir == "" {
+			dir = sqlstorage.DialectFor(cfg.DB.SQL.Driver).Migrations
+		}
+
+		up, down, err := migrator.Create(dir, args[0])
+		if err != nil {
+			return err
+		}
+		log.Printf("created %s and %s", up, down)
+
+		return nil
 	}
 
-	driver, err := pgx.WithInstance(db, &pgx.Config{})
+	m, err := migrator.New(cfg.DB.SQL, initstorage.GetDsn(cfg.DB.SQL))
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer m.Close()
+
+	if err := runMigrator(m, command, args); err != nil {
+		return err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance("file://./migrations/", "postgres", driver)
+	return printStatus(m)
+}
+
+func runMigrator(m *migrator.Migrator, command string, args []string) error {
+	switch command {
+	case "up":
+		n, err := optionalNumber(args, 0)
+		if err != nil {
+			return err
+		}
+
+		return m.Up(n)
+	case "down":
+		n, err := optionalNumber(args, 1)
+		if err != nil {
+			return err
+		}
+
+		return m.Down(n)
+	case "goto":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad version: %w", err)
+		}
+
+		return m.Goto(uint(version))
+	case "force":
+		if len(args) != 1 {
+			return errUsage
+		}
+
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("bad version: %w", err)
+		}
+
+		return m.Force(version)
+	case "status":
+		return nil
+	}
+
+	return errUsage
+}
+
+func optionalNumber(args []string, fallback int) (int, error) {
+	switch len(args) {
+	case 0:
+		return fallback, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("the number of migrations must be positive, got %q", args[0])
+		}
+
+		return n, nil
+	}
+
+	return 0, errUsage
+}
+
+func printStatus(m *migrator.Migrator) error {
+	status, err := m.Status()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	dirty := ""
+	if status.Dirty {
+		dirty = " (dirty, fix the database and force the version)"
 	}
+	log.Printf("version %d%s", status.Version, dirty)
 
-	if err := m.Up(); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			log.Fatal(err)
+	for _, migration := range status.Migrations {
+		state := "pending"
+		if migration.Applied {
+			state = "applied"
 		}
-		log.Println(err)
-		os.Exit(0)
+		log.Printf("  %06d %-30s %s", migration.Version, migration.Name, state)
 	}
 
-	log.Println("successfully migrations")
+	return nil
 }
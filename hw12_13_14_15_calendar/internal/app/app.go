@@ -2,23 +2,31 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Storage interface {
-	AddEvent(event storage.Event) error
-	ChangeEvent(id uuid.UUID, event storage.Event) error
-	RemoveEvent(id uuid.UUID) error
-	ListEventsByRange(p storage.DateRange) (map[uuid.UUID]storage.Event, error)
+	AddEvent(ctx context.Context, event storage.Event) error
+	ChangeEvent(ctx context.Context, id uuid.UUID, event storage.Event) error
+	RemoveEvent(ctx context.Context, id uuid.UUID) error
+	GetEvent(ctx context.Context, id uuid.UUID) (storage.Event, error)
+	ListEventsByRange(ctx context.Context, p storage.DateRange) (map[uuid.UUID]storage.Event, error)
 	Connect(ctx context.Context) error
+	Ping(ctx context.Context) error
 	Close() error
 }
 
+var ErrForbidden = errors.New("access to the event is forbidden")
+
 type App struct {
 	config  config.Config
 	storage Storage
@@ -70,33 +78,143 @@ func buildEvent(id, title, start, end, desc, userID, when string) (*storage.Even
 	return event, nil
 }
 
-func (a *App) CreateEvent(id, title, start, end, desc, userID, when string) error {
+// checkOwner allows anonymous requests (auth disabled) and requests of the owner itself.
+func checkOwner(ctx context.Context, ownerID uuid.UUID) error {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if ok && userID != ownerID {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+func (a *App) CreateEvent(ctx context.Context, id, title, start, end, desc, userID, when string) (err error) {
+	ctx, span := tracing.Start(ctx, "app.CreateEvent", attribute.String("event.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	event, err := buildEvent(id, title, start, end, desc, userID, when)
 	if err != nil {
 		return err
 	}
 
-	return a.storage.AddEvent(*event)
+	return a.addEvent(ctx, *event)
 }
 
-func (a *App) UpdateEvent(id, title, start, end, desc, userID, when string) error {
+// AddEvent is CreateEvent for an event already parsed.
+func (a *App) AddEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "app.AddEvent", attribute.String("event.id", event.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return a.addEvent(ctx, event)
+}
+
+func (a *App) addEvent(ctx context.Context, event storage.Event) error {
+	if err := checkOwner(ctx, event.UserID); err != nil {
+		return err
+	}
+
+	return a.storage.AddEvent(ctx, event)
+}
+
+func (a *App) UpdateEvent(ctx context.Context, id, title, start, end, desc, userID, when string) (err error) {
+	ctx, span := tracing.Start(ctx, "app.UpdateEvent", attribute.String("event.id", id))
+	defer func() { tracing.End(span, err) }()
+
 	event, err := buildEvent(id, title, start, end, desc, userID, when)
 	if err != nil {
 		return err
 	}
 
-	return a.storage.ChangeEvent(event.ID, *event)
+	return a.changeEvent(ctx, *event)
+}
+
+// ChangeEvent is UpdateEvent for an event already parsed.
+func (a *App) ChangeEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "app.ChangeEvent", attribute.String("event.id", event.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return a.changeEvent(ctx, event)
+}
+
+func (a *App) changeEvent(ctx context.Context, event storage.Event) error {
+	if err := checkOwner(ctx, event.UserID); err != nil {
+		return err
+	}
+
+	if _, ok := auth.UserIDFromContext(ctx); ok {
+		old, err := a.storage.GetEvent(ctx, event.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkOwner(ctx, old.UserID); err != nil {
+			return err
+		}
+	}
+
+	return a.storage.ChangeEvent(ctx, event.ID, event)
 }
 
-func (a *App) DeleteEvent(id string) error {
-	return a.storage.RemoveEvent(uuid.MustParse(id))
+func (a *App) DeleteEvent(ctx context.Context, id string) (err error) {
+	ctx, span := tracing.Start(ctx, "app.DeleteEvent", attribute.String("event.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("bad Id. %w", err)
+	}
+
+	if _, ok := auth.UserIDFromContext(ctx); ok {
+		event, err := a.storage.GetEvent(ctx, parsedID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkOwner(ctx, event.UserID); err != nil {
+			return err
+		}
+	}
+
+	return a.storage.RemoveEvent(ctx, parsedID)
 }
 
-func (a *App) FindEventsByPeriod(start, end time.Time) (map[uuid.UUID]storage.Event, error) {
+func (a *App) GetEvent(ctx context.Context, id string) (event storage.Event, err error) {
+	ctx, span := tracing.Start(ctx, "app.GetEvent", attribute.String("event.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return event, fmt.Errorf("bad Id. %w", err)
+	}
+
+	event, err = a.storage.GetEvent(ctx, parsedID)
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	if err := checkOwner(ctx, event.UserID); err != nil {
+		return storage.Event{}, err
+	}
+
+	return event, nil
+}
+
+func (a *App) FindEventsByPeriod(
+	ctx context.Context,
+	start, end time.Time,
+) (events map[uuid.UUID]storage.Event, err error) {
+	ctx, span := tracing.Start(ctx, "app.FindEventsByPeriod")
+	defer func() { tracing.End(span, err) }()
+
 	dateRange := storage.DateRange{
 		Start: start,
 		End:   end,
 	}
 
-	return a.storage.ListEventsByRange(dateRange)
+	// An authenticated user sees only their own events.
+	if userID, ok := auth.UserIDFromContext(ctx); ok {
+		dateRange.UserID = userID
+	}
+
+	return a.storage.ListEventsByRange(ctx, dateRange)
 }
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/google/uuid"
+)
+
+// APIKeyVerifier keeps only hashes of the keys, so lookups do not compare raw secrets.
+type APIKeyVerifier struct {
+	keys map[[sha256.Size]byte]uuid.UUID
+}
+
+func NewAPIKeyVerifier(keys []config.APIKey) (*APIKeyVerifier, error) {
+	v := &APIKeyVerifier{
+		keys: make(map[[sha256.Size]byte]uuid.UUID, len(keys)),
+	}
+
+	for _, k := range keys {
+		if k.Key == "" {
+			return nil, errors.New("empty api key")
+		}
+
+		userID, err := uuid.Parse(k.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("bad userId of api key. %w", err)
+		}
+
+		v.keys[sha256.Sum256([]byte(k.Key))] = userID
+	}
+
+	return v, nil
+}
+
+func (v *APIKeyVerifier) Verify(c Credentials) (uuid.UUID, error) {
+	if c.APIKey == "" {
+		return uuid.Nil, ErrNoCredentials
+	}
+
+	userID, ok := v.keys[sha256.Sum256([]byte(c.APIKey))]
+	if !ok {
+		return uuid.Nil, fmt.Errorf("%w: unknown api key", ErrInvalidCredentials)
+	}
+
+	return userID, nil
+}
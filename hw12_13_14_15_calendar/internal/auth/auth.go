@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Credentials are the raw values taken from a request: a bearer token and/or a static API key.
+type Credentials struct {
+	Token  string
+	APIKey string
+}
+
+// Verifier checks credentials and returns the ID of the authenticated user.
+// It returns ErrNoCredentials when the credentials it understands are absent.
+type Verifier interface {
+	Verify(c Credentials) (uuid.UUID, error)
+}
+
+// Chain tries verifiers in order and uses the first one that found its credentials.
+type Chain []Verifier
+
+func (c Chain) Verify(cred Credentials) (uuid.UUID, error) {
+	for _, v := range c {
+		userID, err := v.Verify(cred)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		return userID, err
+	}
+
+	return uuid.Nil, ErrNoCredentials
+}
+
+// New builds a verifier from the configuration. It returns nil when authentication is disabled.
+func New(cfg config.Auth) (Verifier, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	chain := Chain{}
+
+	if cfg.JWT.Algorithm != "" {
+		v, err := NewJWTVerifier(cfg.JWT)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, v)
+	}
+
+	if len(cfg.APIKeys) > 0 {
+		v, err := NewAPIKeyVerifier(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, v)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("auth is enabled, but neither jwt nor api keys are configured")
+	}
+
+	return chain, nil
+}
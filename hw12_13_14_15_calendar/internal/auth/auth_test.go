@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.Nil(t, err)
+
+	return token
+}
+
+func TestJWTVerifier(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("hs256", func(t *testing.T) {
+		v, err := NewJWTVerifier(config.JWTAuth{Algorithm: "HS256", Secret: "secret", Issuer: "calendar"})
+		require.Nil(t, err)
+
+		token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			Issuer:    "calendar",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+
+		result, err := v.Verify(Credentials{Token: token})
+		require.Nil(t, err)
+		require.Equal(t, userID, result)
+
+		tests := []string{
+			signToken(t, jwt.SigningMethodHS256, []byte("other"), jwt.RegisteredClaims{
+				Subject: userID.String(),
+				Issuer:  "calendar",
+			}),
+			signToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.RegisteredClaims{
+				Subject:   userID.String(),
+				Issuer:    "calendar",
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			}),
+			signToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.RegisteredClaims{
+				Subject: userID.String(),
+				Issuer:  "somebody",
+			}),
+			signToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.RegisteredClaims{
+				Subject: "admin",
+				Issuer:  "calendar",
+			}),
+			"not a token",
+		}
+
+		for _, token := range tests {
+			_, err := v.Verify(Credentials{Token: token})
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+
+		_, err = v.Verify(Credentials{})
+		require.ErrorIs(t, err, ErrNoCredentials)
+	})
+
+	t.Run("rs256", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.Nil(t, err)
+
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.Nil(t, err)
+
+		keyFile := filepath.Join(t.TempDir(), "public.pem")
+		err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+		require.Nil(t, err)
+
+		v, err := NewJWTVerifier(config.JWTAuth{Algorithm: "RS256", PublicKeyFile: keyFile})
+		require.Nil(t, err)
+
+		token := signToken(t, jwt.SigningMethodRS256, key, jwt.RegisteredClaims{Subject: userID.String()})
+
+		result, err := v.Verify(Credentials{Token: token})
+		require.Nil(t, err)
+		require.Equal(t, userID, result)
+
+		hsToken := signToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.RegisteredClaims{Subject: userID.String()})
+
+		_, err = v.Verify(Credentials{Token: hsToken})
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("bad config", func(t *testing.T) {
+		_, err := NewJWTVerifier(config.JWTAuth{Algorithm: "none"})
+		require.NotNil(t, err)
+
+		_, err = NewJWTVerifier(config.JWTAuth{Algorithm: "HS256"})
+		require.NotNil(t, err)
+
+		_, err = NewJWTVerifier(config.JWTAuth{Algorithm: "RS256", PublicKeyFile: "/not/exist.pem"})
+		require.NotNil(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("disabled", func(t *testing.T) {
+		v, err := New(config.Auth{})
+		require.Nil(t, err)
+		require.Nil(t, v)
+	})
+
+	t.Run("nothing configured", func(t *testing.T) {
+		_, err := New(config.Auth{Enabled: true})
+		require.NotNil(t, err)
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		v, err := New(config.Auth{
+			Enabled: true,
+			JWT:     config.JWTAuth{Algorithm: "HS256", Secret: "secret"},
+			APIKeys: []config.APIKey{{Key: "key", UserID: userID.String()}},
+		})
+		require.Nil(t, err)
+
+		result, err := v.Verify(Credentials{APIKey: "key"})
+		require.Nil(t, err)
+		require.Equal(t, userID, result)
+
+		_, err = v.Verify(Credentials{APIKey: "wrong"})
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+
+		token := signToken(t, jwt.SigningMethodHS256, []byte("secret"), jwt.RegisteredClaims{Subject: userID.String()})
+
+		result, err = v.Verify(Credentials{Token: token})
+		require.Nil(t, err)
+		require.Equal(t, userID, result)
+
+		_, err = v.Verify(Credentials{})
+		require.ErrorIs(t, err, ErrNoCredentials)
+	})
+}
@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type ctxKey struct{}
+
+func ContextWithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, ctxKey{}, userID)
+}
+
+// UserIDFromContext returns the authenticated user, ok is false for anonymous requests.
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(ctxKey{}).(uuid.UUID)
+
+	return userID, ok
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+type JWTVerifier struct {
+	algorithm string
+	key       interface{}
+	issuer    string
+	audience  string
+}
+
+func NewJWTVerifier(cfg config.JWTAuth) (*JWTVerifier, error) {
+	v := &JWTVerifier{
+		algorithm: cfg.Algorithm,
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+	}
+
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("jwt secret is required for %s", cfg.Algorithm)
+		}
+		v.key = []byte(cfg.Secret)
+	case jwt.SigningMethodRS256.Alg():
+		pem, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt public key: %w", err)
+		}
+
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse jwt public key: %w", err)
+		}
+		v.key = key
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm: %q", cfg.Algorithm)
+	}
+
+	return v, nil
+}
+
+func (v *JWTVerifier) Verify(c Credentials) (uuid.UUID, error) {
+	if c.Token == "" {
+		return uuid.Nil, ErrNoCredentials
+	}
+
+	claims := &jwt.RegisteredClaims{}
+
+	_, err := jwt.ParseWithClaims(c.Token, claims, func(*jwt.Token) (interface{}, error) {
+		return v.key, nil
+	}, jwt.WithValidMethods([]string{v.algorithm}))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return uuid.Nil, fmt.Errorf("%w: bad issuer", ErrInvalidCredentials)
+	}
+
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return uuid.Nil, fmt.Errorf("%w: bad audience", ErrInvalidCredentials)
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: bad subject: %v", ErrInvalidCredentials, err)
+	}
+
+	return userID, nil
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Config struct {
 	Logger     LoggerConf
 	DB         DB
@@ -7,6 +9,14 @@ type Config struct {
 	GRPCServer GRPCServer
 	Rmq        Rmq
 	QueueName  string
+	Auth       Auth
+	Limits     Limits
+	Metrics    Metrics
+	Tracing    Tracing
+	Health     Health
+	Shutdown   Shutdown
+	Scheduler  Scheduler
+	Sender     Sender
 }
 
 type LoggerConf struct {
@@ -14,27 +24,69 @@ type LoggerConf struct {
 }
 
 type DB struct {
-	Type string // "mem", "sql"
+	Type string // "mem", "sql", "file"
 	SQL  SQLDatabase
+	File FileDatabase
+}
+
+// FileDatabase is a directory with the write-ahead log and the snapshot of the events.
+type FileDatabase struct {
+	Path          string
+	SnapshotEvery int // the log is compacted after so many records once larger than the snapshot, 1000 when not set
 }
 
 type SQLDatabase struct {
-	Driver   string
-	Name     string
-	User     string
-	Password string
-	Host     string
-	Port     string
+	Driver      string
+	Name        string // the database file path for the sqlite3 driver
+	User        string
+	Password    string
+	Host        string
+	Port        string
+	SSLMode     string // "disable" by default, see libpq sslmode
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	MaxOpenConns    int           // 20 when not set
+	MaxIdleConns    int           // 5 when not set
+	ConnMaxLifetime time.Duration // 30m when not set
+	ConnMaxIdleTime time.Duration // 5m when not set
+	ConnectRetries  int           // the pings repeated before the start fails, 10 when not set
+	QueryRetries    int           // the repeats of a statement failed with a transient error, 3 when not set
+	RetryBackoff    time.Duration // the first delay between the attempts, doubled up to 5s, 200ms when not set
+
+	Replicas             []string      // the DSNs of the read replicas serving the event listings, file only
+	ReplicaCheckInterval time.Duration // 5s when not set
+
+	AutoMigrate bool // the calendar applies the pending migrations on start
 }
 
 type Server struct {
 	Port string
 	Host string
+	TLS  TLS
 }
 
 type GRPCServer struct {
 	Port string
 	Host string
+	TLS  TLS
+}
+
+// TLS of a listener. Client certificates are required and verified when ClientCAFile is set.
+type TLS struct {
+	Enabled      bool
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+type ClientTLS struct {
+	Enabled    bool
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
 }
 
 type Rmq struct {
@@ -42,8 +94,154 @@ type Rmq struct {
 	Port string
 	User string
 	Pswd string
+	TLS  ClientTLS
+}
+
+// Metrics is the standalone /metrics listener, it is kept off the public API of the calendar.
+type Metrics struct {
+	Host string
+	Port string
+}
+
+type Shutdown struct {
+	Timeout time.Duration // 10s when not set
+}
+
+// Scheduler settings are applied on SIGHUP without a restart.
+type Scheduler struct {
+	NotifyInterval  time.Duration // 30s when not set
+	CleanupInterval time.Duration // 10m when not set
+	CleanupCron     string        // e.g. "30 3 * * *", overrides the cleanup interval
+	Retention       time.Duration // events ended earlier are removed, 8760h when not set
+	Lookahead       time.Duration // reminders due within it are queued ahead with their delivery time
+	Lease           time.Duration // the replica running the jobs renews it every third, 30s when not set
+}
+
+// Sender template is a text/template over the event, the default one is used when it is empty.
+// A reminder goes by the channel of its event, else of its user, else by the default Channel.
+// A failed delivery is retried, then the reminder goes to the dead-letter queue, the queue name and ".dead".
+type Sender struct {
+	Template     string
+	Channel      string        // "log", "stdout", "smtp", "webhook" or "telegram", "log" when not set
+	Retries      int           // the redeliveries of a reminder failed on its channel, 5 when not set
+	RetryBackoff time.Duration // the delay before the first redelivery, doubled up to 1h, 1m when not set
+	Users        []UserChannel
+	SMTP         SMTPChannel
+	Webhook      WebhookChannel
+	Telegram     TelegramChannel
+}
+
+// UserChannel is the preference of a user, Address is the email or the chat id on the channel.
+type UserChannel struct {
+	UserID  string
+	Channel string
+	Address string
 }
 
+type SMTPChannel struct {
+	Host     string
+	Port     string // 587 when not set
+	Username string
+	Password string
+	From     string
+	Subject  string // "Reminder: " and the event title when not set
+}
+
+// WebhookChannel posts the reminders as JSON to URL.
+type WebhookChannel struct {
+	URL     string
+	Timeout time.Duration // 10s when not set
+}
+
+// TelegramChannel sends the reminders by the sendMessage method of a Telegram-style bot API.
+type TelegramChannel struct {
+	Token   string
+	APIURL  string        // https://api.telegram.org when not set
+	Timeout time.Duration // 10s when not set
+}
+
+// Health is the standalone /healthz and /readyz listener of the scheduler and the sender.
+type Health struct {
+	Host string
+	Port string
+}
+
+type Tracing struct {
+	Exporter    string            // "otlp", "stdout" or "none", tracing is off when not set
+	Endpoint    string            // host:port of the OTLP/HTTP collector
+	Insecure    bool              // plain HTTP to the collector
+	TLS         ClientTLS         // the CA and the client certificate of an HTTPS collector
+	Headers     map[string]string // sent with every export, like the API key of a hosted collector
+	SampleRatio float64           // 1 when not set
+}
+
+// Limits are applied per client: the authenticated user or the remote IP.
+type Limits struct {
+	RPS          float64 // 0 disables rate limiting
+	Burst        int
+	MaxBodyBytes int64 // 1 MiB when not set
+}
+
+type Auth struct {
+	Enabled bool
+	JWT     JWTAuth
+	APIKeys []APIKey
+}
+
+type JWTAuth struct {
+	Algorithm     string // "HS256", "RS256"
+	Secret        string
+	PublicKeyFile string
+	Issuer        string
+	Audience      string
+}
+
+type APIKey struct {
+	Key    string
+	UserID string
+}
+
+// NewConfig returns the defaults, the file, the environment and the flags are applied over them.
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		Logger: LoggerConf{Level: "info"},
+		DB: DB{
+			Type: "mem",
+			SQL: SQLDatabase{
+				Driver:          "pgx",
+				Port:            "5432",
+				SSLMode:         "disable",
+				MaxOpenConns:    20,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: 30 * time.Minute,
+				ConnMaxIdleTime: 5 * time.Minute,
+				ConnectRetries:  10,
+				QueryRetries:    3,
+				RetryBackoff:    200 * time.Millisecond,
+
+				ReplicaCheckInterval: 5 * time.Second,
+			},
+			File: FileDatabase{SnapshotEvery: 1000},
+		},
+		Server:     Server{Port: "8080"},
+		GRPCServer: GRPCServer{Port: "8081"},
+		Rmq:        Rmq{Port: "5672"},
+		QueueName:  "sender",
+		Limits:     Limits{MaxBodyBytes: 1 << 20},
+		Shutdown:   Shutdown{Timeout: 10 * time.Second},
+		Scheduler: Scheduler{
+			NotifyInterval:  30 * time.Second,
+			CleanupInterval: 10 * time.Minute,
+			Retention:       365 * 24 * time.Hour,
+			Lease:           30 * time.Second,
+		},
+		Sender: Sender{
+			Channel:      "log",
+			Retries:      5,
+			RetryBackoff: time.Minute,
+			SMTP:         SMTPChannel{Port: "587"},
+			Webhook:      WebhookChannel{Timeout: 10 * time.Second},
+			Telegram:     TelegramChannel{APIURL: "https://api.telegram.org", Timeout: 10 * time.Second},
+		},
+	}
 }
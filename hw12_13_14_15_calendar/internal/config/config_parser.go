@@ -1,23 +1,200 @@
 package config
 
 import (
-	"log"
+	"flag"
+	"fmt"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	yaml "gopkg.in/yaml.v3"
 )
 
+const envPrefix = "CALENDAR_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Flags are the command-line overrides by the setting path, e.g. "db.sql.host".
+type Flags map[string]string
+
+// setting is a scalar leaf of the config addressed by its YAML path.
+type setting struct {
+	path  string
+	value reflect.Value
+}
+
+// Load builds the configuration from the defaults, the YAML file, the CALENDAR_* environment variables and
+// the command-line flags, each layer overriding the previous one, and validates it with the rules.
+// An empty path skips the file, so a container can be configured with the environment only.
+func Load(path string, flags Flags, rules ...Rule) (*Config, error) {
+	cfg := NewConfig()
+
+	if path != "" {
+		if err := parseFile(path, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := applyFlags(cfg, flags); err != nil {
+		return nil, err
+	}
+
+	if err := Validate(cfg, rules...); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Parse reads the YAML file over the defaults without the other layers and the validation.
 func Parse(filePath string) (*Config, error) {
+	cfg := NewConfig()
+	if err := parseFile(filePath, cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func parseFile(filePath string, cfg *Config) error {
 	configData, err := os.ReadFile(filePath)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	cfg := NewConfig()
-	err = yaml.Unmarshal(configData, cfg)
-	if err != nil {
-		return nil, err
+	if err := yaml.Unmarshal(configData, cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
+// BindFlags registers a flag per setting, named by its path. Only the flags given on the command line
+// end up in the returned overrides.
+func BindFlags(fs *flag.FlagSet) Flags {
+	flags := Flags{}
+
+	for _, s := range settings(NewConfig()) {
+		path := s.path
+		fs.Func(path, "overrides "+path+", also "+EnvName(path), func(value string) error {
+			flags[path] = value
+
+			return nil
+		})
+	}
+
+	return flags
+}
+
+// EnvName is the environment variable of the setting, e.g. CALENDAR_DB_SQL_HOST for "db.sql.host".
+func EnvName(path string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, s := range settings(cfg) {
+		name := EnvName(s.path)
+
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+
+		if err := setValue(s.value, value); err != nil {
+			return fmt.Errorf("bad value of %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyFlags(cfg *Config, flags Flags) error {
+	for _, s := range settings(cfg) {
+		value, ok := flags[s.path]
+		if !ok {
+			continue
+		}
+
+		if err := setValue(s.value, value); err != nil {
+			return fmt.Errorf("bad value of -%s: %w", s.path, err)
+		}
+	}
+
+	return nil
+}
+
+// settings lists the scalar fields of the config. Lists, like the API keys, can only be set in the file.
+func settings(cfg *Config) []setting {
+	return collect("", reflect.ValueOf(cfg).Elem(), false, nil)
+}
+
+// collect lists the scalar fields of v, and the slices and the maps as well when lists is set.
+func collect(prefix string, v reflect.Value, lists bool, result []setting) []setting {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		path := strings.ToLower(t.Field(i).Name)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		field := v.Field(i)
+
+		switch kind := field.Kind(); {
+		case kind == reflect.Struct:
+			result = collect(path, field, lists, result)
+		case kind == reflect.String, kind == reflect.Bool, kind == reflect.Int, kind == reflect.Int64,
+			kind == reflect.Float64:
+			result = append(result, setting{path: path, value: field})
+		case lists && (kind == reflect.Slice || kind == reflect.Map):
+			result = append(result, setting{path: path, value: field})
+		}
+	}
+
+	return result
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+
+		return nil
+	}
+
+	switch kind := v.Kind(); {
+	case kind == reflect.String:
+		v.SetString(raw)
+	case kind == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case kind == reflect.Int, kind == reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case kind == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
 	}
 
-	return cfg, err
+	return nil
 }
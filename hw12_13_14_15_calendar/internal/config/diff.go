@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const masked = "***"
+
+// secretWords mark the settings whose values are not written to the log.
+var secretWords = []string{"password", "pswd", "secret", "token"}
+
+// Change is a setting that differs between two configurations.
+type Change struct {
+	Path string
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Path, c.Old, c.New)
+}
+
+// Under reports whether the setting is one of the prefixes or nested in it, e.g. "limits" covers "limits.rps".
+func (c Change) Under(prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if c.Path == prefix || strings.HasPrefix(c.Path, prefix+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Diff lists the changed settings in the order of the config, the secrets are masked.
+// The lists, like the API keys or the replica DSNs, may hold secrets and are logged by the length only.
+func Diff(old, cur *Config) []Change {
+	var changes []Change
+
+	curSettings := collect("", reflect.ValueOf(cur).Elem(), true, nil)
+	for i, s := range collect("", reflect.ValueOf(old).Elem(), true, nil) {
+		curValue := curSettings[i].value
+
+		if kind := s.value.Kind(); kind == reflect.Slice || kind == reflect.Map {
+			if s.value.Len() == 0 && curValue.Len() == 0 || reflect.DeepEqual(s.value.Interface(), curValue.Interface()) {
+				continue
+			}
+
+			changes = append(changes, Change{Path: s.path, Old: items(s.value.Len()), New: items(curValue.Len())})
+
+			continue
+		}
+
+		oldValue := fmt.Sprint(s.value.Interface())
+		newValue := fmt.Sprint(curValue.Interface())
+
+		if oldValue == newValue {
+			continue
+		}
+
+		if isSecret(s.path) {
+			oldValue, newValue = masked, masked
+		}
+
+		changes = append(changes, Change{Path: s.path, Old: oldValue, New: newValue})
+	}
+
+	return changes
+}
+
+func items(n int) string {
+	if n == 1 {
+		return "1 item"
+	}
+
+	return fmt.Sprintf("%d items", n)
+}
+
+func isSecret(path string) bool {
+	for _, word := range secretWords {
+		if strings.Contains(path, word) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/cron"
+	"github.com/google/uuid"
+)
+
+var logLevels = map[string]bool{
+	"panic": true, "fatal": true, "error": true, "warn": true, "warning": true, "info": true, "debug": true, "trace": true,
+}
+
+// Rule checks the settings one binary depends on and returns the problems found.
+type Rule func(cfg *Config) []string
+
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the common settings and the given rules, reporting all problems at once.
+func Validate(cfg *Config, rules ...Rule) error {
+	problems := common(cfg)
+	for _, rule := range rules {
+		problems = append(problems, rule(cfg)...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func required(problems []string, path, value string) []string {
+	if value == "" {
+		return append(problems, path+" is required (or "+EnvName(path)+")")
+	}
+
+	return problems
+}
+
+func common(cfg *Config) []string {
+	var problems []string
+
+	if !logLevels[strings.ToLower(cfg.Logger.Level)] {
+		problems = append(problems, "logger.level "+cfg.Logger.Level+" is unknown")
+	}
+
+	switch cfg.Tracing.Exporter {
+	case "", "none", "stdout":
+	case "otlp":
+		problems = required(problems, "tracing.endpoint", cfg.Tracing.Endpoint)
+	default:
+		problems = append(problems, "tracing.exporter "+cfg.Tracing.Exporter+" is unknown")
+	}
+
+	if cfg.Shutdown.Timeout < 0 {
+		problems = append(problems, "shutdown.timeout must not be negative")
+	}
+
+	return problems
+}
+
+func listener(problems []string, path string, port string, tls TLS) []string {
+	problems = required(problems, path+".port", port)
+
+	if tls.Enabled {
+		problems = required(problems, path+".tls.certfile", tls.CertFile)
+		problems = required(problems, path+".tls.keyfile", tls.KeyFile)
+	}
+
+	return problems
+}
+
+// Storage is the rule of the binaries using the events storage.
+func Storage(cfg *Config) []string {
+	var problems []string
+
+	switch cfg.DB.Type {
+	case "mem":
+	case "sql":
+		problems = sqlDatabase(problems, cfg.DB.SQL)
+	case "file":
+		problems = required(problems, "db.file.path", cfg.DB.File.Path)
+		if cfg.DB.File.SnapshotEvery < 1 {
+			problems = append(problems, "db.file.snapshotevery must be positive")
+		}
+	default:
+		problems = append(problems, `db.type must be "mem", "sql" or "file"`)
+	}
+
+	return problems
+}
+
+func sqlDatabase(problems []string, db SQLDatabase) []string {
+	problems = required(problems, "db.sql.name", db.Name)
+
+	switch db.Driver {
+	case "pgx":
+		problems = required(problems, "db.sql.host", db.Host)
+		problems = required(problems, "db.sql.port", db.Port)
+		problems = required(problems, "db.sql.user", db.User)
+	case "sqlite3":
+	default:
+		problems = append(problems, `db.sql.driver must be "pgx" or "sqlite3"`)
+	}
+
+	if db.MaxOpenConns < 1 {
+		problems = append(problems, "db.sql.maxopenconns must be positive")
+	}
+	if db.MaxIdleConns < 0 || db.MaxIdleConns > db.MaxOpenConns {
+		problems = append(problems, "db.sql.maxidleconns must be between 0 and db.sql.maxopenconns")
+	}
+	if db.ConnectRetries < 0 || db.QueryRetries < 0 {
+		problems = append(problems, "db.sql.connectretries and db.sql.queryretries must not be negative")
+	}
+	if db.RetryBackoff <= 0 {
+		problems = append(problems, "db.sql.retrybackoff must be positive")
+	}
+	if len(db.Replicas) > 0 && db.ReplicaCheckInterval <= 0 {
+		problems = append(problems, "db.sql.replicacheckinterval must be positive")
+	}
+
+	return problems
+}
+
+// Servers is the rule of the calendar API.
+func Servers(cfg *Config) []string {
+	var problems []string
+
+	problems = listener(problems, "server", cfg.Server.Port, cfg.Server.TLS)
+	problems = listener(problems, "grpcserver", cfg.GRPCServer.Port, cfg.GRPCServer.TLS)
+
+	if cfg.Limits.RPS < 0 || cfg.Limits.Burst < 0 || cfg.Limits.MaxBodyBytes < 0 {
+		problems = append(problems, "limits must not be negative")
+	}
+
+	return problems
+}
+
+// Queue is the rule of the scheduler and the sender.
+func Queue(cfg *Config) []string {
+	var problems []string
+
+	problems = required(problems, "rmq.host", cfg.Rmq.Host)
+	problems = required(problems, "rmq.port", cfg.Rmq.Port)
+	problems = required(problems, "queuename", cfg.QueueName)
+
+	return problems
+}
+
+// Schedule is the rule of the scheduler.
+func Schedule(cfg *Config) []string {
+	var problems []string
+
+	if cfg.Scheduler.NotifyInterval <= 0 {
+		problems = append(problems, "scheduler.notifyinterval must be positive")
+	}
+
+	if cfg.Scheduler.CleanupInterval <= 0 {
+		problems = append(problems, "scheduler.cleanupinterval must be positive")
+	}
+
+	if cfg.Scheduler.CleanupCron != "" {
+		if _, err := cron.Parse(cfg.Scheduler.CleanupCron); err != nil {
+			problems = append(problems, "scheduler.cleanupcron: "+err.Error())
+		}
+	}
+
+	if cfg.Scheduler.Retention <= 0 {
+		problems = append(problems, "scheduler.retention must be positive")
+	}
+
+	if cfg.Scheduler.Lease <= 0 {
+		problems = append(problems, "scheduler.lease must be positive")
+	}
+
+	if cfg.Scheduler.Lookahead < 0 {
+		problems = append(problems, "scheduler.lookahead must not be negative")
+	}
+
+	return problems
+}
+
+// SenderChannels are the channels the sender delivers the reminders by.
+var SenderChannels = []string{"log", "stdout", "smtp", "webhook", "telegram"}
+
+// Notifications is the rule of the sender.
+func Notifications(cfg *Config) []string {
+	var problems []string
+
+	if cfg.Sender.Template != "" {
+		if _, err := template.New("notification").Parse(cfg.Sender.Template); err != nil {
+			problems = append(problems, "sender.template: "+err.Error())
+		}
+	}
+
+	if cfg.Sender.Retries < 0 {
+		problems = append(problems, "sender.retries must not be negative")
+	}
+	if cfg.Sender.RetryBackoff <= 0 {
+		problems = append(problems, "sender.retrybackoff must be positive")
+	}
+
+	used := map[string]bool{}
+	problems = senderChannel(problems, "sender.channel", cfg.Sender.Channel, used)
+
+	for i, user := range cfg.Sender.Users {
+		path := fmt.Sprintf("sender.users[%d]", i)
+		if _, err := uuid.Parse(user.UserID); err != nil {
+			problems = append(problems, path+".userid: "+err.Error())
+		}
+		problems = senderChannel(problems, path+".channel", user.Channel, used)
+	}
+
+	if used["smtp"] {
+		problems = required(problems, "sender.smtp.host", cfg.Sender.SMTP.Host)
+		problems = required(problems, "sender.smtp.port", cfg.Sender.SMTP.Port)
+		problems = required(problems, "sender.smtp.from", cfg.Sender.SMTP.From)
+	}
+
+	if used["webhook"] {
+		problems = required(problems, "sender.webhook.url", cfg.Sender.Webhook.URL)
+	}
+
+	if used["telegram"] {
+		problems = required(problems, "sender.telegram.token", cfg.Sender.Telegram.Token)
+		problems = required(problems, "sender.telegram.apiurl", cfg.Sender.Telegram.APIURL)
+	}
+
+	return problems
+}
+
+func senderChannel(problems []string, path, channel string, used map[string]bool) []string {
+	for _, known := range SenderChannels {
+		if channel == known {
+			used[channel] = true
+
+			return problems
+		}
+	}
+
+	return append(problems, path+" must be one of "+strings.Join(SenderChannels, ", "))
+}
@@ -0,0 +1,49 @@
+package sender
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// dedupSize is the number of the delivered message IDs remembered, a redelivery comes soon after the original.
+const dedupSize = 10000
+
+// dedup remembers the last delivered message IDs, the oldest one is forgotten first. It is a best effort:
+// the IDs live in the memory of one sender, a restart or another replica sends a redelivered reminder again.
+type dedup struct {
+	mu    sync.Mutex
+	seen  map[uuid.UUID]struct{}
+	order []uuid.UUID
+	next  int
+}
+
+func newDedup(size int) *dedup {
+	return &dedup{
+		seen:  make(map[uuid.UUID]struct{}, size),
+		order: make([]uuid.UUID, size),
+	}
+}
+
+func (d *dedup) Seen(id uuid.UUID) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.seen[id]
+
+	return ok
+}
+
+func (d *dedup) Add(id uuid.UUID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.seen[id]; ok {
+		return
+	}
+
+	delete(d.seen, d.order[d.next])
+	d.order[d.next] = id
+	d.next = (d.next + 1) % len(d.order)
+	d.seen[id] = struct{}{}
+}
@@ -4,9 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
 
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/notify"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Logger interface {
@@ -14,10 +24,26 @@ type Logger interface {
 	Errorf(format string, args ...interface{})
 }
 
+const (
+	// DeadLetterSuffix names the dead-letter queue after the queue of the sender.
+	DeadLetterSuffix = ".dead"
+	maxRetryBackoff  = time.Hour
+)
+
+// DefaultTemplate is the notification text when the config has no template.
+const DefaultTemplate = `Dear user. A notice to you "{{.Title}}"{{with .Description}}: {{.}}{{end}} on {{.DatetimeStart}}`
+
 type Sender struct {
 	logger    Logger
 	queue     queue.Queue
 	queueName string
+
+	mu       sync.RWMutex
+	template *template.Template
+	router   *notify.Router
+	retries  int
+	backoff  time.Duration
+	dedup    *dedup
 }
 
 func NewSender(queue queue.Queue, logger Logger, queueName string) *Sender {
@@ -25,34 +51,189 @@ func NewSender(queue queue.Queue, logger Logger, queueName string) *Sender {
 		logger:    logger,
 		queue:     queue,
 		queueName: queueName,
+		template:  template.Must(template.New("notification").Parse(DefaultTemplate)),
+		router:    notify.NewRouter(config.Sender{Channel: "log"}, logger),
+		retries:   5,
+		backoff:   time.Minute,
+		dedup:     newDedup(dedupSize),
 	}
 }
 
-func (s Sender) Send(body []byte) {
-	data := storage.Event{}
+// SetTemplate replaces the notification text, an empty text restores the default one.
+func (s *Sender) SetTemplate(text string) error {
+	if text == "" {
+		text = DefaultTemplate
+	}
 
-	err := json.Unmarshal(body, &data)
+	t, err := template.New("notification").Parse(text)
 	if err != nil {
-		s.logger.Errorf("unmarshal body error: %e", err)
+		return fmt.Errorf("failed to parse the template: %w", err)
 	}
 
-	if data.Description == "" {
-		s.logger.Infof("Dear user. A notice to you \"%s\" on %s", data.Title, data.DatetimeStart)
-	} else {
-		s.logger.Infof("Dear user. A notice to you \"%s\": %s on %s", data.Title, data.Description, data.DatetimeStart)
+	s.mu.Lock()
+	s.template = t
+	s.mu.Unlock()
+
+	return nil
+}
+
+// SetRouter replaces the channels of the reminders, all of them go to the log by default.
+func (s *Sender) SetRouter(router *notify.Router) {
+	s.mu.Lock()
+	s.router = router
+	s.mu.Unlock()
+}
+
+// SetRetries sets the redeliveries of a reminder failed on its channel and the delay before the first one,
+// the delay doubles on every next one up to an hour.
+func (s *Sender) SetRetries(retries int, backoff time.Duration) {
+	s.mu.Lock()
+	s.retries = retries
+	s.backoff = backoff
+	s.mu.Unlock()
+}
+
+func (s *Sender) render(event storage.Event) (string, error) {
+	s.mu.RLock()
+	t := s.template
+	s.mu.RUnlock()
+
+	var b strings.Builder
+	if err := t.Execute(&b, event); err != nil {
+		return "", fmt.Errorf("failed to render the notification: %w", err)
 	}
+
+	return b.String(), nil
+}
+
+// Send delivers the notification, a reminder queued ahead goes back to the queue until its delivery time.
+func (s *Sender) Send(ctx context.Context, body []byte) error {
+	return s.send(ctx, body)
 }
 
-func (s Sender) Run(ctx context.Context) error {
-	err := s.queue.Connect(ctx)
+// Run sends the notifications until ctx is done. The message in progress is finished first.
+func (s *Sender) Run(ctx context.Context) error {
+	err := s.queue.Receive(ctx, s.queueName, func(msgCtx context.Context, body []byte) error {
+		return s.send(msgCtx, body)
+	})
 	if err != nil {
-		return fmt.Errorf("error connect to rmq: %w", err)
+		return fmt.Errorf("error of send: %w", err)
+	}
+
+	return nil
+}
+
+// send acknowledges a message once it is delivered, postponed, queued for a retry or dead-lettered, so no
+// reminder is lost. An error brings the message back after a pause, it comes only from the queue itself.
+func (s *Sender) send(ctx context.Context, body []byte) error {
+	var data storage.Notification
+	if err := json.Unmarshal(body, &data); err != nil {
+		return s.deadLetter(ctx, body, fmt.Errorf("unmarshal body error: %w", err))
+	}
+
+	// A reminder queued ahead waits in the queue, so it holds up none of the messages behind it.
+	if wait := time.Until(data.DeliverAt); wait > 0 {
+		if err := s.queue.SentAfter(ctx, body, s.queueName, wait); err != nil {
+			return fmt.Errorf("failed to postpone the message: %w", err)
+		}
+		metrics.MessagePostponed()
+
+		return nil
+	}
+
+	delivered, err := s.deliver(ctx, data)
+
+	switch {
+	case err != nil:
+		return s.retry(ctx, data, body, err)
+	case !delivered:
+		metrics.MessageDuplicated()
+	default:
+		metrics.MessageProcessed()
 	}
 
-	err = s.queue.Receive(s.queueName, s.Send)
+	return nil
+}
+
+// retry queues the reminder again after a delay doubled on every attempt. A failure a retry cannot fix
+// and a reminder out of the retries are dead-lettered.
+func (s *Sender) retry(ctx context.Context, data storage.Notification, body []byte, cause error) error {
+	s.mu.RLock()
+	retries, backoff := s.retries, s.backoff
+	s.mu.RUnlock()
+
+	if notify.Permanent(cause) || data.Attempt >= retries {
+		return s.deadLetter(ctx, body, cause)
+	}
+
+	for i := 0; i < data.Attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+
+	// The wait queues hand the message back early for the long delays, then it is postponed up to DeliverAt.
+	data.Attempt++
+	data.DeliverAt = time.Now().Add(backoff)
+	next, err := json.Marshal(data)
 	if err != nil {
-		return fmt.Errorf("error of send: %w", err)
+		return fmt.Errorf("marshal body error: %w", err)
+	}
+
+	if err := s.queue.SentAfter(ctx, next, s.queueName, backoff); err != nil {
+		return fmt.Errorf("failed to queue the retry of %s: %w", data.ID, err)
+	}
+
+	s.logger.Errorf("%s, retry %d of %d in %s", cause, data.Attempt, retries, backoff)
+	metrics.MessageRetried()
+
+	return nil
+}
+
+// deadLetter moves the message to the dead-letter queue as it is, for an operator to look into.
+func (s *Sender) deadLetter(ctx context.Context, body []byte, cause error) error {
+	name := s.queueName + DeadLetterSuffix
+	if err := s.queue.Sent(ctx, body, name); err != nil {
+		return fmt.Errorf("failed to dead-letter the message: %w", err)
 	}
 
+	s.logger.Errorf("%s, the message is moved to %s", cause, name)
+	metrics.MessageFailed()
+
 	return nil
 }
+
+// deliver reports false for a duplicate of a message delivered by this sender since its start.
+func (s *Sender) deliver(ctx context.Context, data storage.Notification) (delivered bool, err error) {
+	ctx, span := tracing.Start(ctx, "sender.Send")
+	defer func() { tracing.End(span, err) }()
+
+	span.SetAttributes(attribute.String("event.id", data.ID.String()))
+
+	// The messages without an ID come from the older schedulers.
+	if data.MessageID != uuid.Nil && s.dedup.Seen(data.MessageID) {
+		s.logger.Infof("skip duplicate message %s", data.MessageID)
+
+		return false, nil
+	}
+
+	text, err := s.render(data.Event)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	router := s.router
+	s.mu.RUnlock()
+
+	if err := router.Send(ctx, data.Event, text); err != nil {
+		return false, err
+	}
+
+	if data.MessageID != uuid.Nil {
+		s.dedup.Add(data.MessageID)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,184 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/notify"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+type published struct {
+	name  string
+	body  []byte
+	delay time.Duration
+}
+
+type fakeQueue struct {
+	published []published
+}
+
+func (q *fakeQueue) Connect(context.Context) error { return nil }
+
+func (q *fakeQueue) Ping(context.Context) error { return nil }
+
+func (q *fakeQueue) Receive(context.Context, string, func(ctx context.Context, body []byte) error) error {
+	return nil
+}
+
+func (q *fakeQueue) Sent(ctx context.Context, body []byte, name string) error {
+	return q.SentAfter(ctx, body, name, 0)
+}
+
+func (q *fakeQueue) SentAfter(_ context.Context, body []byte, name string, delay time.Duration) error {
+	q.published = append(q.published, published{name: name, body: body, delay: delay})
+
+	return nil
+}
+
+func (q *fakeQueue) Close() error { return nil }
+
+func TestSendTemplate(t *testing.T) {
+	logg := &testLogger{}
+	s := NewSender(nil, logg, "sender")
+
+	body, err := json.Marshal(storage.Event{
+		Title:         "meeting",
+		DatetimeStart: time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC),
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, s.Send(context.Background(), body))
+
+	require.NotNil(t, s.SetTemplate("{{.Title"))
+	require.Nil(t, s.SetTemplate("{{.Title}} at {{.DatetimeStart.Format \"15:04\"}}"))
+	require.Nil(t, s.Send(context.Background(), body))
+
+	require.Equal(t, []string{
+		`Dear user. A notice to you "meeting" on 2022-05-01 10:00:00 +0000 UTC`,
+		"meeting at 10:00",
+	}, logg.lines)
+}
+
+func TestSendScheduled(t *testing.T) {
+	logg := &testLogger{}
+	q := &fakeQueue{}
+	s := NewSender(q, logg, "sender")
+
+	deliverAt := time.Now().Add(time.Hour)
+	body, err := json.Marshal(storage.Notification{
+		Event:     storage.Event{Title: "meeting"},
+		DeliverAt: deliverAt,
+	})
+	require.Nil(t, err)
+
+	// A reminder queued ahead goes back to the queue at once instead of holding up the next messages.
+	require.Nil(t, s.Send(context.Background(), body))
+	require.Empty(t, logg.lines)
+	require.Len(t, q.published, 1)
+	require.Equal(t, "sender", q.published[0].name)
+	require.Equal(t, body, q.published[0].body)
+	require.InDelta(t, time.Hour, q.published[0].delay, float64(time.Second))
+
+	body, err = json.Marshal(storage.Notification{
+		Event:     storage.Event{Title: "meeting"},
+		DeliverAt: time.Now().Add(-time.Second),
+	})
+	require.Nil(t, err)
+
+	require.Nil(t, s.Send(context.Background(), body))
+	require.Len(t, logg.lines, 1)
+	require.Len(t, q.published, 1)
+
+	require.Nil(t, s.Send(context.Background(), []byte("{")))
+	require.Len(t, logg.lines, 2)
+	require.Equal(t, published{name: "sender.dead", body: []byte("{")}, q.published[1])
+}
+
+func TestSendRetries(t *testing.T) {
+	status := http.StatusBadGateway
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	logg := &testLogger{}
+	q := &fakeQueue{}
+	s := NewSender(q, logg, "sender")
+	s.SetRouter(notify.NewRouter(config.Sender{
+		Channel: "webhook",
+		Webhook: config.WebhookChannel{URL: ts.URL, Timeout: time.Second},
+	}, logg))
+	s.SetRetries(2, time.Minute)
+
+	body, err := json.Marshal(storage.NewNotification(storage.Event{Title: "meeting"}, time.Now()))
+	require.Nil(t, err)
+
+	// Every retry is queued with the attempt counted and a doubled delay, the last failure is dead-lettered.
+	for attempt, delay := range []time.Duration{time.Minute, 2 * time.Minute} {
+		require.Nil(t, s.Send(context.Background(), body))
+		require.Len(t, q.published, 2*attempt+1)
+
+		retry := q.published[2*attempt]
+		require.Equal(t, "sender", retry.name)
+		require.Equal(t, delay, retry.delay)
+
+		var data storage.Notification
+		require.Nil(t, json.Unmarshal(retry.body, &data))
+		require.Equal(t, attempt+1, data.Attempt)
+		require.WithinDuration(t, time.Now().Add(delay), data.DeliverAt, time.Second)
+
+		// A retry handed back by the wait queue ahead of time waits out the rest of the delay.
+		require.Nil(t, s.Send(context.Background(), retry.body))
+		require.Len(t, q.published, 2*attempt+2)
+		require.Equal(t, retry.body, q.published[2*attempt+1].body)
+		require.InDelta(t, delay, q.published[2*attempt+1].delay, float64(time.Second))
+
+		data.DeliverAt = time.Now()
+		body, err = json.Marshal(data)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, s.Send(context.Background(), body))
+	require.Equal(t, published{name: "sender.dead", body: body}, q.published[4])
+
+	// A refused reminder is dead-lettered at once.
+	status = http.StatusBadRequest
+	body, err = json.Marshal(storage.NewNotification(storage.Event{Title: "meeting"}, time.Now()))
+	require.Nil(t, err)
+
+	require.Nil(t, s.Send(context.Background(), body))
+	require.Equal(t, published{name: "sender.dead", body: body}, q.published[5])
+}
+
+func TestSendDeduplicates(t *testing.T) {
+	logg := &testLogger{}
+	s := NewSender(nil, logg, "sender")
+
+	body, err := json.Marshal(storage.NewNotification(storage.Event{Title: "meeting"}, time.Now()))
+	require.Nil(t, err)
+
+	require.Nil(t, s.Send(context.Background(), body))
+	require.Nil(t, s.Send(context.Background(), body))
+	require.Len(t, logg.lines, 2)
+	require.Contains(t, logg.lines[1], "skip duplicate message")
+}
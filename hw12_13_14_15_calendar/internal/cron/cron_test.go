@@ -0,0 +1,53 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// Wednesday.
+	now := time.Date(2022, time.June, 1, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2022, time.June, 1, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, time.June, 1, 10, 30, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2022, time.June, 2, 3, 30, 0, 0, time.UTC)},
+		{"@daily", time.Date(2022, time.June, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2022, time.June, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2022, time.June, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			require.Nil(t, err)
+			require.Equal(t, tt.next, s.Next(now))
+		})
+	}
+}
+
+func TestNextHalfHourZone(t *testing.T) {
+	india := time.FixedZone("IST", 5*60*60+30*60)
+	now := time.Date(2022, time.June, 1, 1, 10, 0, 0, india)
+
+	s, err := Parse("0 3 * * *")
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2022, time.June, 1, 3, 0, 0, 0, india), s.Next(now))
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(expr)
+		require.NotNil(t, err, expr)
+	}
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+)
+
+const checkTimeout = 3 * time.Second
+
+// Check reports whether a dependency of the service is reachable.
+type Check func(ctx context.Context) error
+
+// Checker runs the registered dependency checks for the readiness probes.
+type Checker struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+type Response struct {
+	Status string
+	Checks map[string]string `json:",omitempty"`
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks: make(map[string]Check),
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = check
+}
+
+// Check runs all checks concurrently and returns the failed ones by name.
+func (c *Checker) Check(ctx context.Context) map[string]error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = make(map[string]error)
+	)
+
+	for name, check := range c.checks {
+		wg.Add(1)
+
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			if err := check(ctx); err != nil {
+				mu.Lock()
+				failed[name] = err
+				mu.Unlock()
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return failed
+}
+
+// Ready is true when all checks pass. A nil checker is always ready.
+func (c *Checker) Ready(ctx context.Context) bool {
+	if c == nil {
+		return true
+	}
+
+	return len(c.Check(ctx)) == 0
+}
+
+// LivenessHandler answers while the process is able to serve HTTP, it does not touch the dependencies.
+func LivenessHandler(w http.ResponseWriter, _ *http.Request) {
+	writeResponse(w, http.StatusOK, Response{Status: "ok"})
+}
+
+// ReadinessHandler answers 503 with the failed checks while any dependency is unavailable.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if c == nil {
+		writeResponse(w, http.StatusOK, Response{Status: "ok"})
+
+		return
+	}
+
+	failed := c.Check(r.Context())
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	res := Response{Status: "ok", Checks: make(map[string]string, len(names))}
+	status := http.StatusOK
+
+	for _, name := range names {
+		if err, ok := failed[name]; ok {
+			res.Checks[name] = err.Error()
+			res.Status = "fail"
+			status = http.StatusServiceUnavailable
+
+			continue
+		}
+
+		res.Checks[name] = "ok"
+	}
+
+	writeResponse(w, status, res)
+}
+
+func writeResponse(w http.ResponseWriter, status int, res Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// Serve runs a standalone /healthz and /readyz listener for the background services until ctx is done.
+// It does nothing when the port is not configured.
+func Serve(ctx context.Context, cfg config.Health, checker *Checker) error {
+	if cfg.Port == "" {
+		<-ctx.Done()
+
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", LivenessHandler)
+	mux.HandleFunc("/readyz", checker.ReadinessHandler)
+
+	server := &http.Server{
+		Addr:    net.JoinHostPort(cfg.Host, cfg.Port),
+		Handler: mux,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func getResponse(t *testing.T, handler http.HandlerFunc) (int, Response) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var res Response
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&res))
+
+	return rec.Code, res
+}
+
+func TestChecker(t *testing.T) {
+	t.Run("liveness", func(t *testing.T) {
+		code, res := getResponse(t, LivenessHandler)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, "ok", res.Status)
+	})
+
+	t.Run("nil checker is ready", func(t *testing.T) {
+		var checker *Checker
+
+		require.True(t, checker.Ready(context.Background()))
+
+		code, _ := getResponse(t, checker.ReadinessHandler)
+		require.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("readiness", func(t *testing.T) {
+		var queueErr error
+
+		checker := NewChecker()
+		checker.Add("storage", func(context.Context) error { return nil })
+		checker.Add("queue", func(context.Context) error { return queueErr })
+
+		code, res := getResponse(t, checker.ReadinessHandler)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, Response{Status: "ok", Checks: map[string]string{"storage": "ok", "queue": "ok"}}, res)
+
+		queueErr = errors.New("not connected to RabbitMQ")
+		require.False(t, checker.Ready(context.Background()))
+
+		code, res = getResponse(t, checker.ReadinessHandler)
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, "fail", res.Status)
+		require.Equal(t, "ok", res.Checks["storage"])
+		require.Equal(t, "not connected to RabbitMQ", res.Checks["queue"])
+	})
+}
@@ -0,0 +1,239 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+var ErrShutdownTimeout = errors.New("shutdown deadline exceeded")
+
+type Logger interface {
+	Info(msg ...interface{})
+	Error(msg ...interface{})
+}
+
+// Component is a part of a service with a managed lifetime. Every func is optional.
+type Component struct {
+	Name string
+	// Start returns once the component is ready to be used by the next ones.
+	Start func(ctx context.Context) error
+	// Run blocks while the component works. It must return soon after ctx is done,
+	// an error stops the whole service.
+	Run func(ctx context.Context) error
+	// Stop releases the component, ctx carries the shutdown deadline.
+	Stop func(ctx context.Context) error
+}
+
+// Manager starts the components in the order they were added and stops them in the reverse order.
+type Manager struct {
+	logger     Logger
+	timeout    time.Duration
+	components []Component
+}
+
+func New(logger Logger, timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &Manager{
+		logger:  logger,
+		timeout: timeout,
+	}
+}
+
+func (m *Manager) Add(components ...Component) {
+	m.components = append(m.components, components...)
+}
+
+// Run starts the components and blocks until ctx is done or one of them fails, then shuts everything down
+// within the timeout. It returns the first start or run failure together with the shutdown errors.
+func (m *Manager) Run(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started, err := m.start(runCtx)
+	if err != nil {
+		cancel()
+
+		return m.shutdown(started, nil, err)
+	}
+
+	var (
+		mu     sync.Mutex
+		runErr error
+	)
+
+	done := make([]chan struct{}, len(started))
+
+	for i, c := range started {
+		done[i] = make(chan struct{})
+		if c.Run == nil {
+			close(done[i])
+
+			continue
+		}
+
+		go func(c Component, done chan struct{}) {
+			defer close(done)
+
+			if err := c.Run(runCtx); err != nil {
+				mu.Lock()
+				if runErr == nil {
+					runErr = fmt.Errorf("%s failed: %w", c.Name, err)
+				}
+				mu.Unlock()
+
+				cancel()
+			}
+		}(c, done[i])
+	}
+
+	<-runCtx.Done()
+	cancel()
+
+	err = m.shutdown(started, done, nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if runErr != nil {
+		return joinErrors(append([]error{runErr}, unwrapAll(err)...))
+	}
+
+	return err
+}
+
+func (m *Manager) start(ctx context.Context) ([]Component, error) {
+	started := make([]Component, 0, len(m.components))
+
+	for _, c := range m.components {
+		if c.Start != nil {
+			if err := c.Start(ctx); err != nil {
+				return started, fmt.Errorf("failed to start %s: %w", c.Name, err)
+			}
+		}
+
+		started = append(started, c)
+		m.logger.Info(c.Name + " started")
+	}
+
+	return started, nil
+}
+
+// shutdown stops the components in the reverse order. Each one is stopped and its Run has returned
+// before the components it depends on are stopped, so in-flight work can still use them.
+func (m *Manager) shutdown(started []Component, done []chan struct{}, cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	errs := make([]error, 0, len(started)+1)
+	if cause != nil {
+		errs = append(errs, cause)
+	}
+
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+
+		if c.Stop != nil {
+			if err := c.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.Name, err))
+			}
+		}
+
+		if done != nil {
+			select {
+			case <-done[i]:
+			case <-ctx.Done():
+			}
+		}
+
+		m.logger.Info(c.Name + " stopped")
+	}
+
+	if ctx.Err() != nil {
+		errs = append(errs, ErrShutdownTimeout)
+	}
+
+	return joinErrors(errs)
+}
+
+func unwrapAll(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	var multi *multiError
+	if errors.As(err, &multi) {
+		return multi.errs
+	}
+
+	return []error{err}
+}
+
+// joinErrors keeps the first error for errors.Is and mentions the rest in the message.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	msg := errs[0].Error()
+	for _, err := range errs[1:] {
+		msg += "; " + err.Error()
+	}
+
+	return &multiError{errs: errs, msg: msg}
+}
+
+type multiError struct {
+	errs []error
+	msg  string
+}
+
+func (e *multiError) Error() string {
+	return e.msg
+}
+
+func (e *multiError) Unwrap() error {
+	return e.errs[0]
+}
+
+// Is matches any of the joined errors, so the shutdown timeout is detected even when it is not the first one.
+func (e *multiError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitTimeout = 2
+)
+
+// ExitCode tells a failed service from one that only missed the shutdown deadline.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	for _, e := range unwrapAll(err) {
+		if !errors.Is(e, ErrShutdownTimeout) && !errors.Is(e, context.DeadlineExceeded) {
+			return ExitFailure
+		}
+	}
+
+	return ExitTimeout
+}
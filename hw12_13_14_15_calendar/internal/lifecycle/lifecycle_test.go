@@ -0,0 +1,152 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Log struct{}
+
+func (l Log) Info(...interface{})  {}
+func (l Log) Error(...interface{}) {}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) component(name string, startErr error) Component {
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			r.add("start " + name)
+
+			return startErr
+		},
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			r.add("done " + name)
+
+			return nil
+		},
+		Stop: func(context.Context) error {
+			r.add("stop " + name)
+
+			return nil
+		},
+	}
+}
+
+func index(events []string, event string) int {
+	for i, e := range events {
+		if e == event {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestManager(t *testing.T) {
+	t.Run("ordered start and stop", func(t *testing.T) {
+		r := &recorder{}
+
+		m := New(Log{}, time.Second)
+		m.Add(r.component("storage", nil), r.component("server", nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		require.Nil(t, m.Run(ctx))
+		require.Len(t, r.events, 6)
+		require.Equal(t, []string{"start storage", "start server"}, r.events[:2])
+		require.Equal(t, "stop storage", r.events[5])
+		require.Less(t, index(r.events, "stop server"), index(r.events, "stop storage"))
+		require.Less(t, index(r.events, "done server"), index(r.events, "stop storage"))
+	})
+
+	t.Run("start failure", func(t *testing.T) {
+		r := &recorder{}
+		errBusy := errors.New("address already in use")
+
+		m := New(Log{}, time.Second)
+		m.Add(r.component("storage", nil), r.component("server", errBusy), r.component("grpc", nil))
+
+		err := m.Run(context.Background())
+		require.ErrorIs(t, err, errBusy)
+		require.Equal(t, ExitFailure, ExitCode(err))
+		require.Equal(t, []string{"start storage", "start server", "stop storage"}, r.events)
+	})
+
+	t.Run("run failure", func(t *testing.T) {
+		errLost := errors.New("connection lost")
+
+		m := New(Log{}, time.Second)
+		m.Add(Component{
+			Name: "sender",
+			Run:  func(context.Context) error { return errLost },
+		})
+
+		err := m.Run(context.Background())
+		require.ErrorIs(t, err, errLost)
+		require.Equal(t, ExitFailure, ExitCode(err))
+	})
+
+	t.Run("waits for run before stopping dependencies", func(t *testing.T) {
+		r := &recorder{}
+
+		m := New(Log{}, time.Second)
+		m.Add(r.component("queue", nil), Component{
+			Name: "scheduler",
+			Run: func(ctx context.Context) error {
+				<-ctx.Done()
+				time.Sleep(20 * time.Millisecond)
+				r.add("tick finished")
+
+				return nil
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.Nil(t, m.Run(ctx))
+		require.Equal(t, []string{"tick finished", "stop queue"}, r.events[len(r.events)-2:])
+	})
+
+	t.Run("shutdown deadline", func(t *testing.T) {
+		m := New(Log{}, 10*time.Millisecond)
+		m.Add(Component{
+			Name: "server",
+			Run: func(context.Context) error {
+				time.Sleep(time.Second)
+
+				return nil
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := m.Run(ctx)
+		require.ErrorIs(t, err, ErrShutdownTimeout)
+		require.Equal(t, ExitTimeout, ExitCode(err))
+	})
+
+	require.Equal(t, ExitOK, ExitCode(nil))
+}
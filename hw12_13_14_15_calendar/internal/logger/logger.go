@@ -2,6 +2,7 @@ package logger
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
@@ -27,6 +28,18 @@ func New(level string) *Logger {
 	return &Logger{}
 }
 
+// SetLevel changes the level of the running process, e.g. on a configuration reload.
+func (l Logger) SetLevel(level string) error {
+	logLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("failed to parse the level: %w", err)
+	}
+
+	logrus.SetLevel(logLevel)
+
+	return nil
+}
+
 func (l Logger) Errorf(format string, args ...interface{}) {
 	logrus.Errorf(format, args...)
 }
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "calendar"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Number of handled API requests.",
+	}, []string{"transport", "route", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "Latency of API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"transport", "route"})
+
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	schedulerTickDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scheduler_tick_duration_seconds",
+		Help:      "Duration of scheduler jobs.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job"})
+
+	notificationsEnqueued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scheduler_notifications_enqueued_total",
+		Help:      "Number of notifications sent to the queue.",
+	})
+
+	schedulerLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_leader",
+		Help:      "1 when the scheduler replica holds the lease and runs the jobs.",
+	})
+
+	senderMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sender_messages_total",
+		Help:      "Number of notifications taken from the queue by the sender.",
+	}, []string{"status"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		requestsTotal,
+		requestDuration,
+		storageDuration,
+		schedulerTickDuration,
+		notificationsEnqueued,
+		schedulerLeader,
+		senderMessages,
+	)
+}
+
+func ObserveHTTPRequest(route string, code int, d time.Duration) {
+	requestsTotal.WithLabelValues("http", route, strconv.Itoa(code)).Inc()
+	requestDuration.WithLabelValues("http", route).Observe(d.Seconds())
+}
+
+func ObserveGRPCRequest(method string, code string, d time.Duration) {
+	requestsTotal.WithLabelValues("grpc", method, code).Inc()
+	requestDuration.WithLabelValues("grpc", method).Observe(d.Seconds())
+}
+
+func ObserveStorage(operation string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+
+	storageDuration.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+func ObserveSchedulerTick(job string, start time.Time) {
+	schedulerTickDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+}
+
+func NotificationEnqueued() {
+	notificationsEnqueued.Inc()
+}
+
+func SetSchedulerLeader(leader bool) {
+	if leader {
+		schedulerLeader.Set(1)
+	} else {
+		schedulerLeader.Set(0)
+	}
+}
+
+func MessageProcessed() {
+	senderMessages.WithLabelValues("processed").Inc()
+}
+
+// MessageFailed counts the messages moved to the dead-letter queue.
+func MessageFailed() {
+	senderMessages.WithLabelValues("failed").Inc()
+}
+
+// MessageRetried counts the failed deliveries queued again.
+func MessageRetried() {
+	senderMessages.WithLabelValues("retried").Inc()
+}
+
+// MessagePostponed counts the reminders sent back to wait for their delivery time.
+func MessagePostponed() {
+	senderMessages.WithLabelValues("postponed").Inc()
+}
+
+func MessageDuplicated() {
+	senderMessages.WithLabelValues("duplicate").Inc()
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve runs a standalone /metrics listener until ctx is done.
+// It does nothing when the port is not configured.
+func Serve(ctx context.Context, cfg config.Metrics) error {
+	if cfg.Port == "" {
+		<-ctx.Done()
+
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	server := &http.Server{
+		Addr:    net.JoinHostPort(cfg.Host, cfg.Port),
+		Handler: mux,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	ObserveHTTPRequest("GET /events/{period}/{YYYY}/{MM}/{DD}", http.StatusOK, time.Millisecond)
+	ObserveGRPCRequest("/event.EventService/Create", "OK", time.Millisecond)
+	ObserveStorage("add_event", time.Now(), errors.New("failed"))
+	ObserveSchedulerTick("notify", time.Now())
+	NotificationEnqueued()
+	MessageProcessed()
+	MessageFailed()
+
+	serv := httptest.NewServer(Handler())
+	defer serv.Close()
+
+	resp, err := http.Get(serv.URL)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(t, err)
+
+	expected := []string{
+		`calendar_requests_total{code="200",route="GET /events/{period}/{YYYY}/{MM}/{DD}",transport="http"} 1`,
+		`calendar_requests_total{code="OK",route="/event.EventService/Create",transport="grpc"} 1`,
+		`calendar_storage_operation_duration_seconds_count{operation="add_event",status="error"} 1`,
+		`calendar_scheduler_tick_duration_seconds_count{job="notify"} 1`,
+		`calendar_scheduler_notifications_enqueued_total 1`,
+		`calendar_sender_messages_total{status="processed"} 1`,
+		`calendar_sender_messages_total{status="failed"} 1`,
+	}
+
+	for _, line := range expected {
+		require.Contains(t, string(body), line)
+	}
+}
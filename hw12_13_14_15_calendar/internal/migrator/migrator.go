@@ -0,0 +1,234 @@
+// Package migrator applies the embedded migrations of the SQL storage dialects.
+package migrator
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	sqlstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/migrations"
+	migrate "github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/pgx"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+var (
+	errBadName  = errors.New("the migration name may only have letters, digits and underscores")
+	migrationRe = regexp.MustCompile(`^(\d+)_`)
+	nameRe      = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// Migration is a migration of the dialect and whether it is applied.
+type Migration struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// Status is the database version, zero when no migration is applied, and the known migrations.
+type Status struct {
+	Version    uint
+	Dirty      bool
+	Migrations []Migration
+}
+
+type Migrator struct {
+	m   *migrate.Migrate
+	dir string
+}
+
+// New opens the database of cfg with the migrations of its dialect.
+func New(cfg config.SQLDatabase, dsn string) (*Migrator, error) {
+	dialect := sqlstorage.DialectFor(cfg.Driver)
+	dir := path.Base(dialect.Migrations)
+
+	src, err := iofs.New(migrations.FS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("open migrations: %w", err)
+	}
+
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	driver, err := withInstance(dialect, db)
+	if err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, dialect.System, driver)
+	if err != nil {
+		_ = driver.Close()
+
+		return nil, err
+	}
+
+	return &Migrator{m: m, dir: dir}, nil
+}
+
+// Migrate applies all the pending migrations to the database of cfg.
+func Migrate(cfg config.SQLDatabase, dsn string) (err error) {
+	m, err := New(cfg, dsn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := m.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	return m.Up(0)
+}
+
+func withInstance(dialect sqlstorage.Dialect, db *sql.DB) (database.Driver, error) {
+	if dialect == sqlstorage.SQLite {
+		return sqlite3.WithInstance(db, &sqlite3.Config{})
+	}
+
+	return pgx.WithInstance(db, &pgx.Config{})
+}
+
+// Up applies n pending migrations, all of them when n is not positive.
+func (m *Migrator) Up(n int) error {
+	if n > 0 {
+		return noChange(m.m.Steps(n))
+	}
+
+	return noChange(m.m.Up())
+}
+
+// Down reverts n applied migrations, all of them when n is not positive.
+func (m *Migrator) Down(n int) error {
+	if n > 0 {
+		return noChange(m.m.Steps(-n))
+	}
+
+	return noChange(m.m.Down())
+}
+
+// Goto migrates up or down to the version.
+func (m *Migrator) Goto(version uint) error {
+	return noChange(m.m.Migrate(version))
+}
+
+// Force sets the version without running the migrations, it clears the dirty flag after a failed migration.
+// The version -1 means no migration is applied.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+func (m *Migrator) Status() (Status, error) {
+	var status Status
+
+	version, dirty, err := m.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return status, err
+	}
+	status.Version, status.Dirty = version, dirty
+
+	src, err := iofs.New(migrations.FS, m.dir)
+	if err != nil {
+		return status, fmt.Errorf("open migrations: %w", err)
+	}
+	defer src.Close()
+
+	status.Migrations, err = list(src, version)
+
+	return status, err
+}
+
+func list(src source.Driver, applied uint) ([]Migration, error) {
+	var result []Migration
+
+	version, err := src.First()
+	for err == nil {
+		up, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, readErr
+		}
+		_ = up.Close()
+
+		result = append(result, Migration{Version: version, Name: name, Applied: version <= applied})
+
+		version, err = src.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (m *Migrator) Close() error {
+	sourceErr, databaseErr := m.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+
+	return databaseErr
+}
+
+// noChange treats a database already at the target version as a success.
+func noChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
+
+// Create adds an empty up and down migration numbered after the last one in dir and returns their paths.
+func Create(dir, name string) (up, down string, err error) {
+	if !nameRe.MatchString(name) {
+		return "", "", errBadName
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	last := 0
+	for _, entry := range entries {
+		match := migrationRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		if number, err := strconv.Atoi(match[1]); err == nil && number > last {
+			last = number
+		}
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", last+1, strings.ToLower(name)))
+	up, down = base+".up.sql", base+".down.sql"
+
+	for _, file := range []string{up, down} {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return "", "", err
+		}
+		if err := f.Close(); err != nil {
+			return "", "", err
+		}
+	}
+
+	return up, down, nil
+}
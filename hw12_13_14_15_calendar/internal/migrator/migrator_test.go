@@ -0,0 +1,71 @@
+package migrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator(t *testing.T) {
+	cfg := config.SQLDatabase{Driver: "sqlite3"}
+	dsn := "file:" + filepath.Join(t.TempDir(), "calendar.db")
+
+	m, err := New(cfg, dsn)
+	require.NoError(t, err)
+	defer m.Close()
+
+	status, err := m.Status()
+	require.NoError(t, err)
+	require.Zero(t, status.Version)
+	require.NotEmpty(t, status.Migrations)
+	last := status.Migrations[len(status.Migrations)-1].Version
+
+	require.NoError(t, m.Up(1))
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.Equal(t, uint(1), status.Version)
+	require.True(t, status.Migrations[0].Applied)
+	require.False(t, status.Migrations[1].Applied)
+
+	require.NoError(t, m.Up(0))
+	require.NoError(t, m.Up(0), "no pending migrations is not an error")
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.Equal(t, last, status.Version)
+
+	require.NoError(t, m.Down(1))
+	require.NoError(t, m.Goto(2))
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.Equal(t, uint(2), status.Version)
+
+	require.NoError(t, m.Force(1))
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.Equal(t, uint(1), status.Version)
+	require.False(t, status.Dirty)
+
+	require.NoError(t, m.Goto(2))
+	require.NoError(t, m.Down(0))
+	status, err = m.Status()
+	require.NoError(t, err)
+	require.Zero(t, status.Version)
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000007_events.up.sql"), nil, 0o600))
+
+	up, down, err := Create(dir, "Add_Tags")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "000008_add_tags.up.sql"), up)
+	require.Equal(t, filepath.Join(dir, "000008_add_tags.down.sql"), down)
+	require.FileExists(t, up)
+	require.FileExists(t, down)
+
+	_, _, err = Create(dir, "bad name")
+	require.ErrorIs(t, err, errBadName)
+}
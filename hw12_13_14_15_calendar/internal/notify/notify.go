@@ -0,0 +1,143 @@
+// Package notify delivers the reminders by the channels of the sender: the log, stdout, SMTP email,
+// an HTTP webhook and a Telegram-style bot API.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	errNoChannel = errors.New("channel is not configured")
+	errNoAddress = errors.New("the user has no address on the channel")
+)
+
+// rejectedError is a failure a retry cannot fix.
+type rejectedError struct {
+	err error
+}
+
+func (e *rejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rejectedError) Unwrap() error {
+	return e.err
+}
+
+func reject(err error) error {
+	return &rejectedError{err: err}
+}
+
+// Permanent reports whether a delivery failed for good: the channel or the address is missing or malformed,
+// or the receiver refused the reminder. The other failures may pass when retried.
+func Permanent(err error) bool {
+	var rejected *rejectedError
+
+	return errors.As(err, &rejected)
+}
+
+// refused reports whether an HTTP answer rejects the request itself, a timeout and a throttling may pass later.
+func refused(code int) bool {
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+type Logger interface {
+	Infof(format string, args ...interface{})
+}
+
+// Message is a rendered reminder. Address is the email or the chat id of the user on the channel,
+// empty when the user has none.
+type Message struct {
+	Event   storage.Event
+	Address string
+	Text    string
+}
+
+// Channel delivers the reminders.
+type Channel interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Router picks the channel of a reminder: the channel of the event, else the first channel of its user
+// in the config, else the default one. The channel of the event is skipped when the sender has no settings
+// for it, the API takes any known channel name.
+type Router struct {
+	logger    Logger
+	channels  map[string]Channel
+	fallback  string
+	preferred map[uuid.UUID]string
+	addresses map[uuid.UUID]map[string]string
+}
+
+// NewRouter sets up the configured channels, the log and stdout need no settings. The config is expected
+// to be checked by config.Notifications.
+func NewRouter(cfg config.Sender, logger Logger) *Router {
+	r := &Router{
+		logger: logger,
+		channels: map[string]Channel{
+			"log":    &Log{logger: logger},
+			"stdout": NewWriter(os.Stdout),
+		},
+		fallback:  cfg.Channel,
+		preferred: make(map[uuid.UUID]string),
+		addresses: make(map[uuid.UUID]map[string]string),
+	}
+
+	if cfg.SMTP.Host != "" {
+		r.channels["smtp"] = NewSMTP(cfg.SMTP)
+	}
+	if cfg.Webhook.URL != "" {
+		r.channels["webhook"] = NewWebhook(cfg.Webhook)
+	}
+	if cfg.Telegram.Token != "" {
+		r.channels["telegram"] = NewTelegram(cfg.Telegram)
+	}
+
+	for _, user := range cfg.Users {
+		id, err := uuid.Parse(user.UserID)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := r.preferred[id]; !ok {
+			r.preferred[id] = user.Channel
+			r.addresses[id] = make(map[string]string)
+		}
+		r.addresses[id][user.Channel] = user.Address
+	}
+
+	return r
+}
+
+// Send delivers the reminder text of the event.
+func (r *Router) Send(ctx context.Context, event storage.Event, text string) error {
+	name := r.fallback
+	if preferred, ok := r.preferred[event.UserID]; ok {
+		name = preferred
+	}
+	if _, ok := r.channels[event.Channel]; ok {
+		name = event.Channel
+	} else if event.Channel != "" {
+		r.logger.Infof("reminder of event %s: %q is not configured, sent by %s", event.ID, event.Channel, name)
+	}
+
+	channel, ok := r.channels[name]
+	if !ok {
+		return fmt.Errorf("reminder of event %s: %q %w", event.ID, name, reject(errNoChannel))
+	}
+
+	msg := Message{Event: event, Address: r.addresses[event.UserID][name], Text: text}
+	if err := channel.Send(ctx, msg); err != nil {
+		return fmt.Errorf("reminder of event %s by %s: %w", event.ID, name, err)
+	}
+
+	return nil
+}
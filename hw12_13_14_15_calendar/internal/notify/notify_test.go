@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+type recorder struct {
+	messages []Message
+}
+
+func (r *recorder) Send(_ context.Context, msg Message) error {
+	r.messages = append(r.messages, msg)
+
+	return nil
+}
+
+type sentMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts one session at a time and answers every command with success.
+func fakeSMTP(t *testing.T) (string, string, <-chan sentMail) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	mails := make(chan sentMail, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			serveSMTP(conn, mails)
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	require.Nil(t, err)
+
+	return host, port, mails
+}
+
+func serveSMTP(conn net.Conn, mails chan<- sentMail) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	var m sentMail
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			m.from = line
+			reply("250 OK")
+		case "RCPT":
+			m.to = append(m.to, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			m.data = data.String()
+			mails <- m
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func testEvent() storage.Event {
+	start := time.Date(2022, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	return storage.Event{
+		ID:            uuid.New(),
+		Title:         "Meeting",
+		DatetimeStart: start,
+		DatetimeEnd:   start.Add(time.Hour),
+		UserID:        uuid.New(),
+	}
+}
+
+func TestRouter(t *testing.T) {
+	event := testEvent()
+	other := testEvent()
+
+	r := NewRouter(config.Sender{
+		Channel: "log",
+		Users: []config.UserChannel{
+			{UserID: event.UserID.String(), Channel: "webhook", Address: "https://example.com/me"},
+			{UserID: event.UserID.String(), Channel: "telegram", Address: "42"},
+		},
+	}, &testLogger{})
+
+	channels := map[string]*recorder{"log": {}, "webhook": {}, "telegram": {}}
+	for name, channel := range channels {
+		r.channels[name] = channel
+	}
+
+	ctx := context.Background()
+	require.Nil(t, r.Send(ctx, event, "preferred"))
+	require.Nil(t, r.Send(ctx, other, "default"))
+
+	event.Channel = "telegram"
+	require.Nil(t, r.Send(ctx, event, "of the event"))
+
+	require.Equal(t, []Message{{Event: other, Text: "default"}}, channels["log"].messages)
+	require.Len(t, channels["webhook"].messages, 1)
+	require.Equal(t, "https://example.com/me", channels["webhook"].messages[0].Address)
+	require.Len(t, channels["telegram"].messages, 1)
+	require.Equal(t, "42", channels["telegram"].messages[0].Address)
+	require.Equal(t, "of the event", channels["telegram"].messages[0].Text)
+
+	// The channel of the event without the settings gives way to the channel of the user.
+	event.Channel = "smtp"
+	require.Nil(t, r.Send(ctx, event, "not configured"))
+	require.Len(t, channels["webhook"].messages, 2)
+	require.Equal(t, "not configured", channels["webhook"].messages[1].Text)
+
+	r = NewRouter(config.Sender{Channel: "smtp"}, &testLogger{})
+	err := r.Send(ctx, other, "lost")
+	require.ErrorIs(t, err, errNoChannel)
+	require.True(t, Permanent(err))
+}
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	require.Nil(t, NewWriter(&b).Send(context.Background(), Message{Text: "Dear user"}))
+	require.Equal(t, "Dear user\n", b.String())
+
+	logger := &testLogger{}
+	require.Nil(t, (&Log{logger: logger}).Send(context.Background(), Message{Text: "Dear user"}))
+	require.Equal(t, []string{"Dear user"}, logger.lines)
+}
+
+func TestSMTP(t *testing.T) {
+	host, port, mails := fakeSMTP(t)
+	channel := NewSMTP(config.SMTPChannel{Host: host, Port: port, From: "Calendar <calendar@example.com>"})
+	event := testEvent()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.Nil(t, channel.Send(ctx, Message{Event: event, Address: "user@example.com", Text: "Dear user"}))
+
+	m := <-mails
+	require.Equal(t, "MAIL FROM:<calendar@example.com>", m.from)
+	require.Equal(t, []string{"RCPT TO:<user@example.com>"}, m.to)
+	require.Contains(t, m.data, "To: <user@example.com>\r\n")
+	require.Contains(t, m.data, "Subject: Reminder: Meeting\r\n")
+	require.True(t, strings.HasSuffix(m.data, "\r\n\r\nDear user\r\n"))
+
+	require.ErrorIs(t, channel.Send(ctx, Message{Event: event, Text: "Dear user"}), errNoAddress)
+	require.True(t, Permanent(channel.Send(ctx, Message{Event: event, Address: "not an email", Text: "Dear user"})))
+}
+
+func TestWebhook(t *testing.T) {
+	event := testEvent()
+
+	var payload WebhookPayload
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		switch payload.Text {
+		case "fail":
+			w.WriteHeader(http.StatusBadGateway)
+		case "refuse":
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	channel := NewWebhook(config.WebhookChannel{URL: ts.URL, Timeout: time.Second})
+	require.Nil(t, channel.Send(context.Background(), Message{Event: event, Text: "Dear user"}))
+	require.Equal(t, WebhookPayload{
+		EventID: event.ID.String(),
+		UserID:  event.UserID.String(),
+		Title:   "Meeting",
+		Start:   event.DatetimeStart,
+		Text:    "Dear user",
+	}, payload)
+
+	err := channel.Send(context.Background(), Message{Event: event, Text: "fail"})
+	require.EqualError(t, err, "webhook answered 502 Bad Gateway")
+	require.False(t, Permanent(err))
+
+	err = channel.Send(context.Background(), Message{Event: event, Text: "refuse"})
+	require.EqualError(t, err, "webhook answered 400 Bad Request")
+	require.True(t, Permanent(err))
+}
+
+func TestTelegram(t *testing.T) {
+	var path, chatID, text string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		chatID = r.PostFormValue("chat_id")
+		text = r.PostFormValue("text")
+		if chatID == "0" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+
+	channel := NewTelegram(config.TelegramChannel{Token: "123:secret", APIURL: ts.URL + "/", Timeout: time.Second})
+	event := testEvent()
+
+	require.Nil(t, channel.Send(context.Background(), Message{Event: event, Address: "42", Text: "Dear user"}))
+	require.Equal(t, "/bot123:secret/sendMessage", path)
+	require.Equal(t, "42", chatID)
+	require.Equal(t, "Dear user", text)
+
+	err := channel.Send(context.Background(), Message{Event: event, Address: "0", Text: "Dear user"})
+	require.EqualError(t, err, "telegram answered 400 Bad Request: Bad Request: chat not found")
+	require.True(t, Permanent(err))
+
+	ts.Close()
+	err = channel.Send(context.Background(), Message{Event: event, Address: "42", Text: "Dear user"})
+	require.Error(t, err)
+	require.False(t, Permanent(err))
+	require.NotContains(t, err.Error(), "secret")
+}
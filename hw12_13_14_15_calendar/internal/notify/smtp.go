@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+)
+
+// smtpTimeout limits a delivery when the context has no deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTP emails the reminders. STARTTLS is used when the server offers it, the credentials are sent only then
+// or to a local server.
+type SMTP struct {
+	cfg config.SMTPChannel
+}
+
+func NewSMTP(cfg config.SMTPChannel) *SMTP {
+	return &SMTP{cfg: cfg}
+}
+
+func (c *SMTP) Send(ctx context.Context, msg Message) error {
+	if msg.Address == "" {
+		return reject(errNoAddress)
+	}
+
+	to, err := mail.ParseAddress(msg.Address)
+	if err != nil {
+		return reject(fmt.Errorf("bad email %q: %w", msg.Address, err))
+	}
+
+	from, err := mail.ParseAddress(c.cfg.From)
+	if err != nil {
+		return reject(fmt.Errorf("bad sender email %q: %w", c.cfg.From, err))
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(c.cfg.Host, c.cfg.Port))
+	if err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		_ = conn.Close()
+
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, c.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+
+		return err
+	}
+	defer client.Close()
+
+	if err := c.deliver(client, from, to, c.message(from, to, msg)); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+func (c *SMTP) deliver(client *smtp.Client, from, to *mail.Address, body []byte) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.cfg.Host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+
+	if c.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// message builds the email, the subject is encoded so a title cannot add headers.
+func (c *SMTP) message(from, to *mail.Address, msg Message) []byte {
+	subject := c.cfg.Subject
+	if subject == "" {
+		subject = "Reminder: " + msg.Event.Title
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Text)
+	b.WriteString("\r\n")
+
+	return b.Bytes()
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+)
+
+// Telegram sends the reminders by the sendMessage method of a bot, the address of a user is the chat id.
+type Telegram struct {
+	endpoint string
+	client   *http.Client
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+func NewTelegram(cfg config.TelegramChannel) *Telegram {
+	return &Telegram{
+		endpoint: strings.TrimSuffix(cfg.APIURL, "/") + "/bot" + cfg.Token + "/sendMessage",
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (c *Telegram) Send(ctx context.Context, msg Message) error {
+	if msg.Address == "" {
+		return reject(errNoAddress)
+	}
+
+	form := url.Values{"chat_id": {msg.Address}, "text": {msg.Text}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.New("bad telegram api url")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// The url holds the bot token, only the cause is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("telegram: %w", urlErr.Err)
+		}
+
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result telegramResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&result); err != nil {
+		return fmt.Errorf("telegram answered %s", resp.Status)
+	}
+
+	if !result.OK {
+		err := fmt.Errorf("telegram answered %s: %s", resp.Status, result.Description)
+		if refused(resp.StatusCode) {
+			return reject(err)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+)
+
+// Webhook posts the reminders as JSON, any 2xx answer is a delivery.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// WebhookPayload is the body of a webhook request.
+type WebhookPayload struct {
+	EventID string    `json:"eventId"`
+	UserID  string    `json:"userId"`
+	Title   string    `json:"title"`
+	Start   time.Time `json:"start"`
+	Address string    `json:"address,omitempty"`
+	Text    string    `json:"text"`
+}
+
+func NewWebhook(cfg config.WebhookChannel) *Webhook {
+	return &Webhook{url: cfg.URL, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+func (c *Webhook) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(WebhookPayload{
+		EventID: msg.Event.ID.String(),
+		UserID:  msg.Event.UserID.String(),
+		Title:   msg.Event.Title,
+		Start:   msg.Event.DatetimeStart,
+		Address: msg.Address,
+		Text:    msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("webhook answered %s", resp.Status)
+		if refused(resp.StatusCode) {
+			return reject(err)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Log writes the reminders to the log, the way the sender always did.
+type Log struct {
+	logger Logger
+}
+
+func (c *Log) Send(_ context.Context, msg Message) error {
+	c.logger.Infof("%s", msg.Text)
+
+	return nil
+}
+
+// Writer writes the reminders to w, a line each.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (c *Writer) Send(_ context.Context, msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprintln(c.w, msg.Text)
+
+	return err
+}
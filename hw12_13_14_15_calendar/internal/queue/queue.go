@@ -1,10 +1,20 @@
 package queue
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Queue interface {
 	Connect(ctx context.Context) error
-	Receive(name string, callback func(body []byte)) error
-	Sent(body []byte, name string) error
+	Ping(ctx context.Context) error
+	// Receive blocks until ctx is done and calls the callback with the trace context of each message.
+	// A message is acknowledged when the callback returns nil and comes back after a pause when it returns
+	// an error.
+	Receive(ctx context.Context, name string, callback func(ctx context.Context, body []byte) error) error
+	Sent(ctx context.Context, body []byte, name string) error
+	// SentAfter publishes the message to the queue name once delay passes. The message may come back
+	// a little earlier, the consumer checks its own delivery time.
+	SentAfter(ctx context.Context, body []byte, name string, delay time.Duration) error
 	Close() error
 }
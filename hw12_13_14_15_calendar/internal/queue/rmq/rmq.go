@@ -2,16 +2,38 @@ package rmqqueue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"time"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tlsconfig"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	errNotConnected     = errors.New("not connected to RabbitMQ")
+	errDeliveriesClosed = errors.New("RabbitMQ closed the deliveries channel")
+	errNotConfirmed     = errors.New("RabbitMQ did not confirm the message")
+)
+
+// waitTiers are the message TTLs of the wait queues. A delayed message waits in the longest one not exceeding
+// its delay and is dead-lettered back to its queue, the TTL is the same for a whole queue, so a message
+// waiting longer never holds up the ones behind it.
+var waitTiers = []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute, time.Hour}
+
+// redeliveryDelay is the pause before a message failed by its callback comes back.
+const redeliveryDelay = 10 * time.Second
+
 type Rmq struct {
 	config config.Config
 	conn   *amqp.Connection
-	ctx    context.Context
 }
 
 func NewRmq(config *config.Config) *Rmq {
@@ -20,12 +42,32 @@ func NewRmq(config *config.Config) *Rmq {
 	}
 }
 
-func (rmq *Rmq) Connect(ctx context.Context) error {
-	rmq.ctx = ctx
-
+func (rmq *Rmq) Connect(_ context.Context) error {
 	c := rmq.config.Rmq
-	url := fmt.Sprintf("amqp://%s:%s@%s:%s/", c.User, c.Pswd, c.Host, c.Port)
-	conn, err := amqp.Dial(url)
+	amqpURL := url.URL{
+		Scheme: "amqp",
+		User:   url.UserPassword(c.User, c.Pswd),
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/",
+	}
+
+	var (
+		conn *amqp.Connection
+		err  error
+	)
+
+	if c.TLS.Enabled {
+		tlsCfg, tlsErr := tlsconfig.ClientConfig(c.TLS)
+		if tlsErr != nil {
+			return fmt.Errorf("failed to load RabbitMQ tls config: %w", tlsErr)
+		}
+
+		amqpURL.Scheme = "amqps"
+		conn, err = amqp.DialTLS(amqpURL.String(), tlsCfg)
+	} else {
+		conn, err = amqp.Dial(amqpURL.String())
+	}
+
 	if err != nil {
 		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
@@ -46,40 +88,126 @@ func getQueue(name string, ch *amqp.Channel) (amqp.Queue, error) {
 	)
 }
 
-func (rmq *Rmq) Sent(body []byte, name string) error {
+func waitTier(delay time.Duration) time.Duration {
+	tier := waitTiers[0]
+	for _, t := range waitTiers {
+		if t <= delay {
+			tier = t
+		}
+	}
+
+	return tier
+}
+
+// getWaitQueue declares the wait queue of name whose messages go back to name after ttl.
+func getWaitQueue(name string, ttl time.Duration, ch *amqp.Channel) (amqp.Queue, error) {
+	return ch.QueueDeclare(
+		name+".wait."+ttl.String(),
+		false,
+		false,
+		false,
+		false,
+		amqp.Table{
+			"x-message-ttl":             ttl.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": name,
+		},
+	)
+}
+
+func messagingAttributes(name string) trace.SpanStartOption {
+	return trace.WithAttributes(
+		semconv.MessagingSystemKey.String("rabbitmq"),
+		semconv.MessagingDestinationKindQueue,
+		semconv.MessagingDestinationKey.String(name),
+	)
+}
+
+func (rmq *Rmq) Sent(ctx context.Context, body []byte, name string) error {
+	return rmq.publish(ctx, body, name, func(ch *amqp.Channel) (amqp.Queue, error) {
+		return getQueue(name, ch)
+	})
+}
+
+// SentAfter waits out a long delay in several hops through the wait queues, the consumer postpones
+// the message again until its time comes. A delay shorter than a second comes up to a second late.
+func (rmq *Rmq) SentAfter(ctx context.Context, body []byte, name string, delay time.Duration) error {
+	return rmq.publish(ctx, body, name, func(ch *amqp.Channel) (amqp.Queue, error) {
+		if _, err := getQueue(name, ch); err != nil {
+			return amqp.Queue{}, err
+		}
+
+		return getWaitQueue(name, waitTier(delay), ch)
+	})
+}
+
+// publish sends the message to the queue made by declare, the span is named after the queue name
+// the message is meant for.
+func (rmq *Rmq) publish(
+	ctx context.Context,
+	body []byte,
+	name string,
+	declare func(ch *amqp.Channel) (amqp.Queue, error),
+) (err error) {
+	ctx, span := otel.Tracer(tracing.InstrumentationName).Start(
+		ctx,
+		name+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		messagingAttributes(name),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	ch, err := rmq.conn.Channel()
 	if err != nil {
 		return fmt.Errorf("failed open channel: %w", err)
 	}
+	defer ch.Close()
 
-	q, err := getQueue(name, ch)
+	q, err := declare(ch)
 	if err != nil {
 		return fmt.Errorf("failed queue declare: %w", err)
 	}
 
-	err = ch.PublishWithContext(
-		rmq.ctx,
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("failed confirm mode: %w", err)
+	}
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(
+		ctx,
 		"",
 		q.Name,
 		false,
 		false,
 		amqp.Publishing{
 			ContentType: "application/json",
+			Headers:     tracing.InjectHeaders(ctx, nil),
 			Body:        body,
 		})
 	if err != nil {
 		return fmt.Errorf("failed publish: %w", err)
 	}
-	defer ch.Close()
+
+	// The message is handed over only when the broker confirms it.
+	if !confirmation.Wait() {
+		return errNotConfirmed
+	}
 
 	return nil
 }
 
-func (rmq *Rmq) Receive(name string, callback func(body []byte)) error {
+// Receive consumes the queue until ctx is done. A message is acknowledged after its callback succeeds,
+// so the messages of an interrupted sender are delivered again. A message failed by the callback goes
+// through a wait queue, so a lasting failure does not redeliver it in a tight loop.
+func (rmq *Rmq) Receive(
+	ctx context.Context,
+	name string,
+	callback func(ctx context.Context, body []byte) error,
+) error {
 	ch, err := rmq.conn.Channel()
 	if err != nil {
 		return fmt.Errorf("failed open channel: %w", err)
 	}
+	defer ch.Close()
 
 	q, err := getQueue(name, ch)
 	if err != nil {
@@ -89,7 +217,7 @@ func (rmq *Rmq) Receive(name string, callback func(body []byte)) error {
 	msg, err := ch.Consume(
 		q.Name,
 		"",
-		true,
+		false,
 		false,
 		false,
 		false,
@@ -99,21 +227,50 @@ func (rmq *Rmq) Receive(name string, callback func(body []byte)) error {
 		return fmt.Errorf("failed open channel: %w", err)
 	}
 
-	var forever chan struct{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-msg:
+			if !ok {
+				return errDeliveriesClosed
+			}
+
+			// The callback is not bound to ctx, a shutdown lets the current message finish.
+			msgCtx, span := otel.Tracer(tracing.InstrumentationName).Start(
+				tracing.ExtractHeaders(context.Background(), d.Headers),
+				name+" receive",
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				messagingAttributes(name),
+			)
+			cbErr := callback(msgCtx, d.Body)
+			tracing.End(span, cbErr)
+
+			// The message is left unacknowledged when it cannot be delayed, the broker requeues it
+			// once the channel is closed.
+			if cbErr != nil {
+				if err := rmq.SentAfter(msgCtx, d.Body, name, redeliveryDelay); err != nil {
+					return fmt.Errorf("failed to delay the message: %w", err)
+				}
+			}
 
-	go func() {
-		for d := range msg {
-			callback(d.Body)
+			if err := d.Ack(false); err != nil {
+				return fmt.Errorf("failed ack: %w", err)
+			}
 		}
-	}()
+	}
+}
 
-	<-forever
+func (rmq *Rmq) Ping(_ context.Context) error {
+	if rmq.conn == nil || rmq.conn.IsClosed() {
+		return errNotConnected
+	}
 
 	return nil
 }
 
 func (rmq *Rmq) Close() error {
-	if !rmq.conn.IsClosed() {
+	if rmq.conn != nil && !rmq.conn.IsClosed() {
 		return rmq.conn.Close()
 	}
 
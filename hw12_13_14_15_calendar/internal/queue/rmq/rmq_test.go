@@ -0,0 +1,16 @@
+package rmqqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitTier(t *testing.T) {
+	require.Equal(t, time.Second, waitTier(0))
+	require.Equal(t, time.Second, waitTier(9*time.Second))
+	require.Equal(t, 10*time.Second, waitTier(59*time.Second))
+	require.Equal(t, 10*time.Minute, waitTier(30*time.Minute))
+	require.Equal(t, time.Hour, waitTier(72*time.Hour))
+}
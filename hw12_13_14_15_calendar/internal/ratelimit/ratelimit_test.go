@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)
+
+	l := New(2, 3)
+	l.now = func() time.Time { return now }
+
+	t.Run("burst and refill", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("first")
+			require.True(t, ok)
+		}
+
+		ok, wait := l.Allow("first")
+		require.False(t, ok)
+		require.Equal(t, 500*time.Millisecond, wait)
+		require.Equal(t, 1, RetryAfter(wait))
+
+		ok, _ = l.Allow("second")
+		require.True(t, ok)
+
+		now = now.Add(500 * time.Millisecond)
+
+		ok, _ = l.Allow("first")
+		require.True(t, ok)
+
+		ok, _ = l.Allow("first")
+		require.False(t, ok)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		now = now.Add(2 * cleanupInterval)
+
+		ok, _ := l.Allow("third")
+		require.True(t, ok)
+		require.Len(t, l.buckets, 1)
+	})
+
+	t.Run("set limits", func(t *testing.T) {
+		l.SetLimits(0, 0)
+
+		for i := 0; i < 100; i++ {
+			ok, _ := l.Allow("first")
+			require.True(t, ok)
+		}
+
+		l.SetLimits(1, 0)
+		ok, _ := l.Allow("fourth")
+		require.True(t, ok)
+
+		ok, wait := l.Allow("fourth")
+		require.False(t, ok)
+		require.Equal(t, time.Second, wait)
+	})
+}
@@ -0,0 +1,78 @@
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+)
+
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Watcher re-reads the configuration on SIGHUP. The settings under the reloadable prefixes are handed to
+// apply, the changes of the others only take effect after a restart.
+type Watcher struct {
+	logger     Logger
+	load       func() (*config.Config, error)
+	apply      func(cfg *config.Config)
+	reloadable []string
+	current    *config.Config
+}
+
+func New(
+	logger Logger,
+	current *config.Config,
+	load func() (*config.Config, error),
+	apply func(cfg *config.Config),
+	reloadable ...string,
+) *Watcher {
+	return &Watcher{
+		logger:     logger,
+		load:       load,
+		apply:      apply,
+		reloadable: reloadable,
+		current:    current,
+	}
+}
+
+// Run reloads on every SIGHUP until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-hup:
+			w.Reload()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Reload loads the configuration and applies it. An invalid configuration is logged and the current one is kept.
+func (w *Watcher) Reload() {
+	cfg, err := w.load()
+	if err != nil {
+		w.logger.Errorf("config reload failed, keeping the current config: %s", err)
+
+		return
+	}
+
+	for _, change := range config.Diff(w.current, cfg) {
+		if change.Under(w.reloadable...) {
+			w.logger.Infof("config reloaded %s", change)
+		} else {
+			w.logger.Infof("config changed %s, restart to apply", change)
+		}
+	}
+
+	w.apply(cfg)
+	w.current = cfg
+}
@@ -0,0 +1,53 @@
+package reload
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestReload(t *testing.T) {
+	logg := &testLogger{}
+
+	next := config.NewConfig()
+	next.Logger.Level = "debug"
+	next.Server.Port = "9090"
+
+	var loadErr error
+	var applied *config.Config
+
+	w := New(logg, config.NewConfig(), func() (*config.Config, error) {
+		return next, loadErr
+	}, func(cfg *config.Config) {
+		applied = cfg
+	}, "logger")
+
+	w.Reload()
+	require.Equal(t, next, applied)
+	require.Equal(t, []string{
+		`config reloaded logger.level: "info" -> "debug"`,
+		`config changed server.port: "8080" -> "9090", restart to apply`,
+	}, logg.lines)
+
+	applied = nil
+	logg.lines = nil
+	loadErr = errors.New("broken")
+	w.Reload()
+	require.Nil(t, applied)
+	require.Equal(t, []string{"config reload failed, keeping the current config: broken"}, logg.lines)
+}
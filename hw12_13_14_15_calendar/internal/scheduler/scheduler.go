@@ -2,14 +2,20 @@ package scheduler
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/cron"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/queue"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type Logger interface {
@@ -19,50 +25,132 @@ type Logger interface {
 }
 
 type Storage interface {
-	RemoveOldEvents(datetime time.Time) error
-	ListEventsForNotification(datetime time.Time) (map[uuid.UUID]storage.Event, error)
-	SetIsNotified(id uuid.UUID) error
+	RemoveOldEvents(ctx context.Context, datetime time.Time) error
+	ClaimNotifications(ctx context.Context, datetime, now time.Time) (int, error)
+	ListOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error)
+	DeleteOutbox(ctx context.Context, id uuid.UUID) error
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
 	Connect(ctx context.Context) error
+	Ping(ctx context.Context) error
 	Close() error
 }
 
+const (
+	// relayBatch is the number of outbox messages read at once.
+	relayBatch = 100
+	// leaseName is the lease of the replica running the jobs.
+	leaseName = "scheduler"
+)
+
 type Scheduler struct {
 	queue     queue.Queue
 	logger    Logger
 	storage   Storage
 	queueName string
+	holder    string
+
+	mu         sync.Mutex
+	cfg        config.Scheduler
+	changed    chan struct{}
+	leaseUntil time.Time
 }
 
-func NewScheduler(queue queue.Queue, logger logger.Logger, storage Storage, queueName string) *Scheduler {
+func NewScheduler(
+	queue queue.Queue,
+	logger logger.Logger,
+	storage Storage,
+	queueName string,
+	cfg config.Scheduler,
+) *Scheduler {
 	return &Scheduler{
 		queue:     queue,
 		logger:    logger,
 		storage:   storage,
 		queueName: queueName,
+		holder:    instanceID(),
+		cfg:       cfg,
+		changed:   make(chan struct{}, 1),
 	}
 }
 
-func (sch *Scheduler) Run(ctx context.Context) error {
-	if err := sch.queue.Connect(ctx); err != nil {
-		return fmt.Errorf("error queue start: %w", err)
+// SetConfig changes the settings of the running scheduler, the timers restart with them.
+func (sch *Scheduler) SetConfig(cfg config.Scheduler) {
+	sch.mu.Lock()
+	sch.cfg = cfg
+	sch.mu.Unlock()
+
+	select {
+	case sch.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (sch *Scheduler) config() config.Scheduler {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+
+	return sch.cfg
+}
+
+// instanceID tells the replicas apart in the lease table.
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "scheduler"
 	}
 
-	notyTimer := time.NewTicker(30 * time.Second)
-	removeTimer := time.NewTicker(10 * time.Minute)
+	return host + "-" + uuid.New().String()[:8]
+}
+
+// Run ticks until ctx is done. Only the replica holding the lease runs the jobs, the others stay on standby
+// and take over when its lease expires. The jobs are not bound to ctx, so a shutdown waits for the current
+// tick instead of leaving the events half notified.
+func (sch *Scheduler) Run(ctx context.Context) error {
+	jobCtx := context.Background()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		sch.heartbeat(ctx)
+	}()
+	defer wg.Wait()
+
+	cfg := sch.config()
+	notyTimer := time.NewTicker(cfg.NotifyInterval)
+	removeTimer := time.NewTimer(sch.untilCleanup(cfg, time.Now()))
 
 	sch.logger.Info("Calendar scheduler started...")
 
 	for {
 		select {
 		case <-notyTimer.C:
-			err := sch.Noty()
+			if !sch.leading(time.Now()) {
+				continue
+			}
+
+			start := time.Now()
+			err := sch.Noty(jobCtx)
 			if err != nil {
-				sch.logger.Errorf("notification error: %e", err)
+				sch.logger.Errorf("notification error: %s", err)
 			}
+			metrics.ObserveSchedulerTick("notify", start)
 		case <-removeTimer.C:
-			if err := sch.Remove(); err != nil {
-				sch.logger.Errorf("remove events error: %e", err)
+			if start := time.Now(); sch.leading(start) {
+				if err := sch.Remove(jobCtx); err != nil {
+					sch.logger.Errorf("remove events error: %s", err)
+				}
+				metrics.ObserveSchedulerTick("cleanup", start)
+			}
+			removeTimer.Reset(sch.untilCleanup(sch.config(), time.Now()))
+		case <-sch.changed:
+			cfg := sch.config()
+			notyTimer.Reset(cfg.NotifyInterval)
+			if !removeTimer.Stop() {
+				<-removeTimer.C
 			}
+			removeTimer.Reset(sch.untilCleanup(cfg, time.Now()))
 		case <-ctx.Done():
 			notyTimer.Stop()
 			removeTimer.Stop()
@@ -72,32 +160,150 @@ func (sch *Scheduler) Run(ctx context.Context) error {
 	}
 }
 
-func (sch *Scheduler) Noty() error {
-	events, err := sch.storage.ListEventsForNotification(time.Now())
+// heartbeat renews the lease every third of its ttl until ctx is done and releases it then,
+// so a standby replica takes over without waiting for the expiry.
+func (sch *Scheduler) heartbeat(ctx context.Context) {
+	sch.renew()
+
+	ticker := time.NewTicker(sch.config().Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			sch.renew()
+			ticker.Reset(sch.config().Lease / 3)
+		case <-ctx.Done():
+			sch.release()
+
+			return
+		}
+	}
+}
+
+func (sch *Scheduler) renew() {
+	ttl := sch.config().Lease
+	start := time.Now()
+
+	acquired, err := sch.storage.AcquireLease(context.Background(), leaseName, sch.holder, ttl)
+	if err != nil {
+		sch.logger.Errorf("lease error: %s", err)
+	}
+
+	sch.mu.Lock()
+	wasLeading := start.Before(sch.leaseUntil)
+	if acquired {
+		sch.leaseUntil = start.Add(ttl)
+	}
+	sch.mu.Unlock()
+
+	switch {
+	case acquired && !wasLeading:
+		sch.logger.Info("scheduler " + sch.holder + " is the leader now")
+	case !acquired && wasLeading:
+		sch.logger.Info("scheduler " + sch.holder + " lost the lease")
+	}
+
+	metrics.SetSchedulerLeader(acquired)
+}
+
+func (sch *Scheduler) release() {
+	sch.mu.Lock()
+	sch.leaseUntil = time.Time{}
+	sch.mu.Unlock()
+
+	if err := sch.storage.ReleaseLease(context.Background(), leaseName, sch.holder); err != nil {
+		sch.logger.Errorf("release lease error: %s", err)
+	}
+
+	metrics.SetSchedulerLeader(false)
+}
+
+// leading reports whether the last renewed lease is still valid, a replica cut off from the storage
+// stops running the jobs before another one takes over.
+func (sch *Scheduler) leading(now time.Time) bool {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+
+	return now.Before(sch.leaseUntil)
+}
+
+// untilCleanup is the wait for the next cleanup, the cron expression takes precedence over the interval.
+func (sch *Scheduler) untilCleanup(cfg config.Scheduler, now time.Time) time.Duration {
+	if cfg.CleanupCron == "" {
+		return cfg.CleanupInterval
+	}
+
+	schedule, err := cron.Parse(cfg.CleanupCron)
 	if err != nil {
-		return err
+		sch.logger.Errorf("cleanup cron error, using the interval: %s", err)
+
+		return cfg.CleanupInterval
+	}
+
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return cfg.CleanupInterval
 	}
 
-	for _, event := range events {
-		body, err := json.Marshal(event)
+	return next.Sub(now)
+}
+
+// Noty claims the due reminders into the outbox and relays the outbox to the queue. A message is deleted
+// only after the queue confirms it, so a crash in between publishes it again: the delivery is at least once.
+// The sender skips the duplicates it has seen itself, a reminder may still be sent twice after its restart.
+func (sch *Scheduler) Noty(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "scheduler.Noty")
+	defer func() { tracing.End(span, err) }()
+
+	now := time.Now()
+
+	// Reminders due within the lookahead are queued now and delivered by the sender on time.
+	if _, err := sch.storage.ClaimNotifications(ctx, now.Add(sch.config().Lookahead), now); err != nil {
+		return fmt.Errorf("claim notifications: %w", err)
+	}
+
+	for {
+		messages, err := sch.storage.ListOutbox(ctx, relayBatch)
 		if err != nil {
-			sch.logger.Errorf("marshal error: %e", err)
+			return fmt.Errorf("list outbox: %w", err)
 		}
 
-		if err := sch.queue.Sent(body, sch.queueName); err != nil {
-			sch.logger.Errorf("sent error: %e", err)
+		for _, message := range messages {
+			if err := sch.publish(ctx, message); err != nil {
+				return err
+			}
 		}
 
-		if err := sch.storage.SetIsNotified(event.ID); err != nil {
-			sch.logger.Errorf("set is notified error: %e", err)
+		if len(messages) < relayBatch {
+			return nil
 		}
 	}
+}
+
+// publish traces each message separately, so the sender continues the trace of its own reminder.
+func (sch *Scheduler) publish(ctx context.Context, message storage.OutboxMessage) (err error) {
+	ctx, span := tracing.Start(ctx, "scheduler.publish", attribute.String("message.id", message.ID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	if err := sch.queue.Sent(ctx, message.Payload, sch.queueName); err != nil {
+		return fmt.Errorf("sent error: %w", err)
+	}
+
+	metrics.NotificationEnqueued()
+
+	if err := sch.storage.DeleteOutbox(ctx, message.ID); err != nil {
+		return fmt.Errorf("delete outbox: %w", err)
+	}
 
 	return nil
 }
 
-func (sch *Scheduler) Remove() error {
-	t := time.Now().AddDate(-1, 0, 0)
+func (sch *Scheduler) Remove(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "scheduler.Remove")
+	defer func() { tracing.End(span, err) }()
+
+	t := time.Now().Add(-sch.config().Retention)
 
-	return sch.storage.RemoveOldEvents(t)
+	return sch.storage.RemoveOldEvents(ctx, t)
 }
@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/logger"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	*memorystorage.Storage
+	until   time.Time
+	removed time.Time
+}
+
+func (f *fakeStorage) RemoveOldEvents(_ context.Context, datetime time.Time) error {
+	f.removed = datetime
+
+	return nil
+}
+
+func (f *fakeStorage) ClaimNotifications(ctx context.Context, datetime, now time.Time) (int, error) {
+	f.until = datetime
+
+	return f.Storage.ClaimNotifications(ctx, datetime, now)
+}
+
+type fakeQueue struct {
+	sent [][]byte
+	err  error
+}
+
+func (q *fakeQueue) Connect(context.Context) error { return nil }
+
+func (q *fakeQueue) Ping(context.Context) error { return nil }
+
+func (q *fakeQueue) Receive(context.Context, string, func(ctx context.Context, body []byte) error) error {
+	return nil
+}
+
+func (q *fakeQueue) Sent(_ context.Context, body []byte, _ string) error {
+	if q.err != nil {
+		return q.err
+	}
+
+	q.sent = append(q.sent, body)
+
+	return nil
+}
+
+func (q *fakeQueue) SentAfter(ctx context.Context, body []byte, name string, _ time.Duration) error {
+	return q.Sent(ctx, body, name)
+}
+
+func (q *fakeQueue) Close() error { return nil }
+
+func newFakeStorage(t *testing.T, events ...storage.Event) *fakeStorage {
+	t.Helper()
+
+	st := &fakeStorage{Storage: memorystorage.New()}
+	for _, event := range events {
+		require.Nil(t, st.AddEvent(context.Background(), event))
+	}
+
+	return st
+}
+
+func sentNotifications(t *testing.T, q *fakeQueue) map[uuid.UUID]storage.Notification {
+	t.Helper()
+
+	result := make(map[uuid.UUID]storage.Notification)
+	for _, body := range q.sent {
+		var n storage.Notification
+		require.Nil(t, json.Unmarshal(body, &n))
+		result[n.ID] = n
+	}
+
+	return result
+}
+
+func TestNotyLookahead(t *testing.T) {
+	now := time.Now()
+	due := storage.Event{ID: uuid.New(), WhenToNotify: now.Add(-time.Minute)}
+	ahead := storage.Event{ID: uuid.New(), WhenToNotify: now.Add(3 * time.Minute)}
+	later := storage.Event{ID: uuid.New(), WhenToNotify: now.Add(time.Hour)}
+
+	st := newFakeStorage(t, due, ahead, later)
+	q := &fakeQueue{}
+
+	cfg := config.NewConfig().Scheduler
+	cfg.Lookahead = 5 * time.Minute
+	sch := NewScheduler(q, logger.Logger{}, st, "sender", cfg)
+
+	require.Nil(t, sch.Noty(context.Background()))
+	require.WithinDuration(t, now.Add(5*time.Minute), st.until, time.Second)
+
+	sent := sentNotifications(t, q)
+	require.Len(t, sent, 2)
+	require.True(t, sent[due.ID].DeliverAt.IsZero())
+	require.True(t, sent[ahead.ID].DeliverAt.Equal(ahead.WhenToNotify))
+
+	require.Nil(t, sch.Noty(context.Background()))
+	require.Len(t, q.sent, 2)
+}
+
+func TestNotyRelayRetry(t *testing.T) {
+	event := storage.Event{ID: uuid.New(), WhenToNotify: time.Now().Add(-time.Minute)}
+	st := newFakeStorage(t, event)
+	q := &fakeQueue{err: errors.New("connection lost")}
+
+	sch := NewScheduler(q, logger.Logger{}, st, "sender", config.NewConfig().Scheduler)
+
+	require.NotNil(t, sch.Noty(context.Background()))
+
+	pending, err := st.ListOutbox(context.Background(), 10)
+	require.Nil(t, err)
+	require.Len(t, pending, 1)
+
+	q.err = nil
+	require.Nil(t, sch.Noty(context.Background()))
+
+	sent := sentNotifications(t, q)
+	require.Equal(t, pending[0].ID, sent[event.ID].MessageID)
+
+	pending, err = st.ListOutbox(context.Background(), 10)
+	require.Nil(t, err)
+	require.Empty(t, pending)
+}
+
+func TestRemoveRetention(t *testing.T) {
+	st := newFakeStorage(t)
+	cfg := config.NewConfig().Scheduler
+	cfg.Retention = 48 * time.Hour
+	sch := NewScheduler(&fakeQueue{}, logger.Logger{}, st, "sender", cfg)
+
+	require.Nil(t, sch.Remove(context.Background()))
+	require.WithinDuration(t, time.Now().Add(-48*time.Hour), st.removed, time.Second)
+
+	now := time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)
+	cfg.CleanupCron = "30 3 * * *"
+	require.Equal(t, 17*time.Hour+30*time.Minute, sch.untilCleanup(cfg, now))
+
+	cfg.CleanupCron = ""
+	require.Equal(t, cfg.CleanupInterval, sch.untilCleanup(cfg, now))
+}
+
+func TestLeaseTakeover(t *testing.T) {
+	st := newFakeStorage(t)
+	cfg := config.NewConfig().Scheduler
+
+	first := NewScheduler(&fakeQueue{}, logger.Logger{}, st, "sender", cfg)
+	second := NewScheduler(&fakeQueue{}, logger.Logger{}, st, "sender", cfg)
+
+	first.renew()
+	second.renew()
+	require.True(t, first.leading(time.Now()))
+	require.False(t, second.leading(time.Now()))
+
+	first.release()
+	second.renew()
+	require.False(t, first.leading(time.Now()))
+	require.True(t, second.leading(time.Now()))
+
+	// A lease not renewed in time is taken over.
+	cfg.Lease = time.Millisecond
+	second.SetConfig(cfg)
+	second.renew()
+	time.Sleep(5 * time.Millisecond)
+	first.renew()
+	require.True(t, first.leading(time.Now()))
+	require.False(t, second.leading(time.Now()))
+}
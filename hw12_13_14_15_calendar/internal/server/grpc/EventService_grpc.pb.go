@@ -25,6 +25,7 @@ type EventServiceClient interface {
 	Create(ctx context.Context, in *Event, opts ...grpc.CallOption) (*EventResponse, error)
 	Update(ctx context.Context, in *Event, opts ...grpc.CallOption) (*EventResponse, error)
 	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Event, error)
 	EventListOfDay(ctx context.Context, in *DateRequest, opts ...grpc.CallOption) (*EventsResponse, error)
 	EventListOfWeek(ctx context.Context, in *DateRequest, opts ...grpc.CallOption) (*EventsResponse, error)
 	EventListOfMonth(ctx context.Context, in *DateRequest, opts ...grpc.CallOption) (*EventsResponse, error)
@@ -65,6 +66,15 @@ func (c *eventServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts
 	return out, nil
 }
 
+func (c *eventServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Event, error) {
+	out := new(Event)
+	err := c.cc.Invoke(ctx, "/event.EventService/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *eventServiceClient) EventListOfDay(ctx context.Context, in *DateRequest, opts ...grpc.CallOption) (*EventsResponse, error) {
 	out := new(EventsResponse)
 	err := c.cc.Invoke(ctx, "/event.EventService/EventListOfDay", in, out, opts...)
@@ -99,6 +109,7 @@ type EventServiceServer interface {
 	Create(context.Context, *Event) (*EventResponse, error)
 	Update(context.Context, *Event) (*EventResponse, error)
 	Delete(context.Context, *DeleteRequest) (*EventResponse, error)
+	Get(context.Context, *GetRequest) (*Event, error)
 	EventListOfDay(context.Context, *DateRequest) (*EventsResponse, error)
 	EventListOfWeek(context.Context, *DateRequest) (*EventsResponse, error)
 	EventListOfMonth(context.Context, *DateRequest) (*EventsResponse, error)
@@ -118,6 +129,9 @@ func (UnimplementedEventServiceServer) Update(context.Context, *Event) (*EventRe
 func (UnimplementedEventServiceServer) Delete(context.Context, *DeleteRequest) (*EventResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
 }
+func (UnimplementedEventServiceServer) Get(context.Context, *GetRequest) (*Event, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
 func (UnimplementedEventServiceServer) EventListOfDay(context.Context, *DateRequest) (*EventsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method EventListOfDay not implemented")
 }
@@ -194,6 +208,24 @@ func _EventService_Delete_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/event.EventService/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _EventService_EventListOfDay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DateRequest)
 	if err := dec(in); err != nil {
@@ -267,6 +299,10 @@ var EventService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Delete",
 			Handler:    _EventService_Delete_Handler,
 		},
+		{
+			MethodName: "Get",
+			Handler:    _EventService_Get_Handler,
+		},
 		{
 			MethodName: "EventListOfDay",
 			Handler:    _EventService_EventListOfDay_Handler,
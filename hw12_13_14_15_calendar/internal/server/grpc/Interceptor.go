@@ -2,9 +2,23 @@ package internalgrpc
 
 import (
 	"context"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
+	"go.opentelemetry.io/otel"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
@@ -20,7 +34,235 @@ func (srv *GRPCServer) RequestLogInterceptor(
 
 	duration := time.Since(start)
 
-	srv.logger.LogGRPCRequest(ctx, info, duration, status.Code(err).String())
+	code := status.Code(err).String()
+	srv.logger.LogGRPCRequest(ctx, info, duration, code)
+	metrics.ObserveGRPCRequest(info.FullMethod, code, duration)
 
 	return i, err
 }
+
+// metadataCarrier adapts the incoming gRPC metadata to the propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (srv *GRPCServer) TracingUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+
+	ctx, span := otel.Tracer(tracing.InstrumentationName).Start(
+		ctx,
+		info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemKey.String("grpc")),
+	)
+	defer span.End()
+
+	i, err := handler(ctx, req)
+
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int64(int64(code)))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+
+	return i, err
+}
+
+// healthPrefix marks the methods of the health service, the probes call them without credentials
+// and are neither authenticated nor rate limited.
+const healthPrefix = "/grpc.health.v1.Health/"
+
+func isHealthCheck(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, healthPrefix)
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate returns the context with the user. A failed attempt costs the client IP a token of the rate limit,
+// over the limit it returns the retry-after header and ResourceExhausted instead of Unauthenticated.
+func (srv *GRPCServer) authenticate(ctx context.Context) (context.Context, metadata.MD, error) {
+	if srv.auth == nil {
+		return ctx, nil, nil
+	}
+
+	cred := auth.Credentials{}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if keys := md.Get("x-api-key"); len(keys) > 0 {
+			cred.APIKey = keys[0]
+		}
+
+		if tokens := md.Get("authorization"); len(tokens) > 0 && strings.HasPrefix(tokens[0], "Bearer ") {
+			cred.Token = strings.TrimPrefix(tokens[0], "Bearer ")
+		}
+	}
+
+	userID, err := srv.auth.Verify(cred)
+	if err != nil {
+		srv.logger.Info("unauthorized request: ", err)
+
+		if md, limitErr := srv.rateLimit(ctx); limitErr != nil {
+			return nil, md, limitErr
+		}
+
+		return nil, nil, status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	return auth.ContextWithUserID(ctx, userID), nil, nil
+}
+
+func (srv *GRPCServer) AuthUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if isHealthCheck(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	userCtx, md, err := srv.authenticate(ctx)
+	if err != nil {
+		if md != nil {
+			if headerErr := grpc.SetHeader(ctx, md); headerErr != nil {
+				srv.logger.Error(headerErr)
+			}
+		}
+
+		return nil, err
+	}
+
+	return handler(userCtx, req)
+}
+
+func (srv *GRPCServer) AuthStreamInterceptor(
+	s interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if isHealthCheck(info.FullMethod) {
+		return handler(s, ss)
+	}
+
+	ctx, md, err := srv.authenticate(ss.Context())
+	if err != nil {
+		if md != nil {
+			if headerErr := ss.SetHeader(md); headerErr != nil {
+				srv.logger.Error(headerErr)
+			}
+		}
+
+		return err
+	}
+
+	return handler(s, &authServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// clientKey identifies the client for rate limiting: the authenticated user, otherwise the peer IP.
+func clientKey(ctx context.Context) string {
+	if userID, ok := auth.UserIDFromContext(ctx); ok {
+		return "user:" + userID.String()
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:"
+	}
+
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		ip = p.Addr.String()
+	}
+
+	return "ip:" + ip
+}
+
+// rateLimit returns the retry-after header and ResourceExhausted when the client is over the limit.
+func (srv *GRPCServer) rateLimit(ctx context.Context) (metadata.MD, error) {
+	ok, wait := srv.limiter.Allow(clientKey(ctx))
+	if ok {
+		return nil, nil
+	}
+
+	md := metadata.Pairs("retry-after", strconv.Itoa(ratelimit.RetryAfter(wait)))
+
+	return md, status.Error(codes.ResourceExhausted, "too many requests")
+}
+
+func (srv *GRPCServer) RateLimitUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	if isHealthCheck(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	md, err := srv.rateLimit(ctx)
+	if err != nil {
+		if headerErr := grpc.SetHeader(ctx, md); headerErr != nil {
+			srv.logger.Error(headerErr)
+		}
+
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (srv *GRPCServer) RateLimitStreamInterceptor(
+	s interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	if isHealthCheck(info.FullMethod) {
+		return handler(s, ss)
+	}
+
+	md, err := srv.rateLimit(ss.Context())
+	if err != nil {
+		if headerErr := ss.SetHeader(md); headerErr != nil {
+			srv.logger.Error(headerErr)
+		}
+
+		return err
+	}
+
+	return handler(s, ss)
+}
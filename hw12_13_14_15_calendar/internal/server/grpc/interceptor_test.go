@@ -0,0 +1,58 @@
+package internalgrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthCheckIsPublic(t *testing.T) {
+	cfg := &config.Config{
+		GRPCServer: config.GRPCServer{Host: "127.0.0.1", Port: "0"},
+		Limits:     config.Limits{RPS: 0.001, Burst: 1},
+	}
+	verifier, err := auth.NewAPIKeyVerifier([]config.APIKey{
+		{Key: "secret", UserID: "9a66db8d-5714-4276-b860-852d888c95a9"},
+	})
+	require.NoError(t, err)
+
+	srv := NewGRPCServer(&Log{}, app.New(memorystorage.New(), cfg), verifier, nil, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, srv.Start(ctx))
+	go func() { _ = srv.Serve(ctx) }()
+	defer srv.Stop(ctx)
+
+	conn, err := grpc.Dial(srv.listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The probes have no credentials and call more often than the limit lets a client.
+	health := healthpb.NewHealthClient(conn)
+	for i := 0; i < 3; i++ {
+		_, err := health.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+
+	events := NewEventServiceClient(conn)
+	_, err = events.Get(ctx, &GetRequest{Id: "9a66db8d-5714-4276-b860-852d888c95a9"})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// A failed authentication spends the token of the IP, so guessing the key is throttled.
+	var header metadata.MD
+	_, err = events.Get(ctx, &GetRequest{Id: "9a66db8d-5714-4276-b860-852d888c95a9"}, grpc.Header(&header))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.NotEmpty(t, header.Get("retry-after"))
+}
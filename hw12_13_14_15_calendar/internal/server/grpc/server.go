@@ -1,20 +1,35 @@
 //go:generate protoc -I ../../../api/ --go_out=. --go-grpc_out=. ../../../api/EventService.proto
+//go:generate protoc -I ../../../api/ --go_out=eventv2 --go-grpc_out=eventv2 ../../../api/v2/EventService.proto
 
 package internalgrpc
 
 import (
 	"context"
+	"errors"
 	"net"
 	"time"
 
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/eventv2"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tlsconfig"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
 type Period int
 
+const healthCheckInterval = 5 * time.Second
+
 const (
 	day Period = iota
 	week
@@ -28,22 +43,50 @@ type Logger interface {
 }
 
 type GRPCServer struct {
-	host   string
-	port   string
-	logger Logger
-	app    Application
-	server *grpc.Server
+	host     string
+	port     string
+	tls      config.TLS
+	reloader *tlsconfig.Reloader
+	logger   Logger
+	app      Application
+	auth     auth.Verifier
+	limiter  *ratelimit.Limiter
+	maxBody  int64
+	checker  *health.Checker
+	health   *grpchealth.Server
+	server   *grpc.Server
+	listener net.Listener
 }
 
 type Application interface {
-	CreateEvent(id, title, start, end, desc, userID, when string) error
-	UpdateEvent(id, title, start, end, desc, userID, when string) error
-	DeleteEvent(id string) error
-	FindEventsByPeriod(start, end time.Time) (map[uuid.UUID]storage.Event, error)
+	CreateEvent(ctx context.Context, id, title, start, end, desc, userID, when string) error
+	UpdateEvent(ctx context.Context, id, title, start, end, desc, userID, when string) error
+	AddEvent(ctx context.Context, event storage.Event) error
+	ChangeEvent(ctx context.Context, event storage.Event) error
+	DeleteEvent(ctx context.Context, id string) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	FindEventsByPeriod(ctx context.Context, start, end time.Time) (map[uuid.UUID]storage.Event, error)
+}
+
+func appError(err error) error {
+	if errors.Is(err, app.ErrForbidden) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrEventNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	if errors.Is(err, storage.ErrEventExists) {
+		return status.Error(codes.AlreadyExists, storage.ErrEventExists.Error())
+	}
+
+	return err
 }
 
-func (srv *GRPCServer) Create(_ context.Context, event *Event) (*EventResponse, error) {
+func (srv *GRPCServer) Create(ctx context.Context, event *Event) (*EventResponse, error) {
 	err := srv.app.CreateEvent(
+		ctx,
 		event.Id,
 		event.Title,
 		event.DatetimeStart,
@@ -55,7 +98,7 @@ func (srv *GRPCServer) Create(_ context.Context, event *Event) (*EventResponse,
 	if err != nil {
 		return &EventResponse{
 			Result: 0,
-		}, err
+		}, appError(err)
 	}
 
 	return &EventResponse{
@@ -63,8 +106,9 @@ func (srv *GRPCServer) Create(_ context.Context, event *Event) (*EventResponse,
 	}, nil
 }
 
-func (srv *GRPCServer) Update(_ context.Context, event *Event) (*EventResponse, error) {
+func (srv *GRPCServer) Update(ctx context.Context, event *Event) (*EventResponse, error) {
 	err := srv.app.UpdateEvent(
+		ctx,
 		event.Id,
 		event.Title,
 		event.DatetimeStart,
@@ -76,7 +120,7 @@ func (srv *GRPCServer) Update(_ context.Context, event *Event) (*EventResponse,
 	if err != nil {
 		return &EventResponse{
 			Result: 0,
-		}, err
+		}, appError(err)
 	}
 
 	return &EventResponse{
@@ -84,12 +128,12 @@ func (srv *GRPCServer) Update(_ context.Context, event *Event) (*EventResponse,
 	}, nil
 }
 
-func (srv *GRPCServer) Delete(_ context.Context, request *DeleteRequest) (*EventResponse, error) {
-	err := srv.app.DeleteEvent(request.Id)
+func (srv *GRPCServer) Delete(ctx context.Context, request *DeleteRequest) (*EventResponse, error) {
+	err := srv.app.DeleteEvent(ctx, request.Id)
 	if err != nil {
 		return &EventResponse{
 			Result: 0,
-		}, err
+		}, appError(err)
 	}
 
 	return &EventResponse{
@@ -97,19 +141,32 @@ func (srv *GRPCServer) Delete(_ context.Context, request *DeleteRequest) (*Event
 	}, nil
 }
 
-func (srv *GRPCServer) EventListOfDay(_ context.Context, request *DateRequest) (*EventsResponse, error) {
-	return srv.getEventListOfPeriod(request, day)
+func (srv *GRPCServer) Get(ctx context.Context, request *GetRequest) (*Event, error) {
+	event, err := srv.app.GetEvent(ctx, request.Id)
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	return toEvent(event), nil
+}
+
+func (srv *GRPCServer) EventListOfDay(ctx context.Context, request *DateRequest) (*EventsResponse, error) {
+	return srv.getEventListOfPeriod(ctx, request, day)
 }
 
-func (srv *GRPCServer) EventListOfWeek(_ context.Context, request *DateRequest) (*EventsResponse, error) {
-	return srv.getEventListOfPeriod(request, week)
+func (srv *GRPCServer) EventListOfWeek(ctx context.Context, request *DateRequest) (*EventsResponse, error) {
+	return srv.getEventListOfPeriod(ctx, request, week)
 }
 
-func (srv *GRPCServer) EventListOfMonth(_ context.Context, request *DateRequest) (*EventsResponse, error) {
-	return srv.getEventListOfPeriod(request, month)
+func (srv *GRPCServer) EventListOfMonth(ctx context.Context, request *DateRequest) (*EventsResponse, error) {
+	return srv.getEventListOfPeriod(ctx, request, month)
 }
 
-func (srv *GRPCServer) getEventListOfPeriod(request *DateRequest, period Period) (*EventsResponse, error) {
+func (srv *GRPCServer) getEventListOfPeriod(
+	ctx context.Context,
+	request *DateRequest,
+	period Period,
+) (*EventsResponse, error) {
 	dateStart, err := time.Parse("2006-01-02", request.Date)
 	if err != nil {
 		return nil, err
@@ -130,26 +187,17 @@ func (srv *GRPCServer) getEventListOfPeriod(request *DateRequest, period Period)
 		}, err
 	}
 
-	result, err := srv.app.FindEventsByPeriod(dateStart, dateEnd)
+	result, err := srv.app.FindEventsByPeriod(ctx, dateStart, dateEnd)
 	if err != nil {
 		return &EventsResponse{
 			Events: nil,
-		}, err
+		}, appError(err)
 	}
 
 	events := make([]*Event, 0)
 
 	for _, item := range result {
-		event := &Event{
-			Id:            item.ID.String(),
-			Title:         item.Title,
-			DatetimeStart: item.DatetimeStart.Format(time.RFC3339),
-			DatetimeEnd:   item.DatetimeEnd.Format(time.RFC3339),
-			Description:   item.Description,
-			UserId:        item.UserID.String(),
-			WhenToNotify:  item.WhenToNotify.Format(time.RFC3339),
-		}
-		events = append(events, event)
+		events = append(events, toEvent(item))
 	}
 
 	return &EventsResponse{
@@ -157,48 +205,150 @@ func (srv *GRPCServer) getEventListOfPeriod(request *DateRequest, period Period)
 	}, nil
 }
 
+func toEvent(item storage.Event) *Event {
+	return &Event{
+		Id:            item.ID.String(),
+		Title:         item.Title,
+		DatetimeStart: item.DatetimeStart.Format(time.RFC3339),
+		DatetimeEnd:   item.DatetimeEnd.Format(time.RFC3339),
+		Description:   item.Description,
+		UserId:        item.UserID.String(),
+		WhenToNotify:  item.WhenToNotify.Format(time.RFC3339),
+	}
+}
+
 func (srv *GRPCServer) mustEmbedUnimplementedEventServiceServer() {
 	srv.logger.Error("unimplemented event")
 }
 
-func NewGRPCServer(logger Logger, app Application, cfg *config.Config) *GRPCServer {
+func NewGRPCServer(
+	logger Logger,
+	app Application,
+	verifier auth.Verifier,
+	checker *health.Checker,
+	cfg *config.Config,
+) *GRPCServer {
 	return &GRPCServer{
-		host:   cfg.GRPCServer.Host,
-		port:   cfg.GRPCServer.Port,
-		logger: logger,
-		app:    app,
+		host:    cfg.GRPCServer.Host,
+		port:    cfg.GRPCServer.Port,
+		tls:     cfg.GRPCServer.TLS,
+		logger:  logger,
+		app:     app,
+		auth:    verifier,
+		limiter: ratelimit.New(cfg.Limits.RPS, cfg.Limits.Burst),
+		maxBody: cfg.Limits.MaxBodyBytes,
+		checker: checker,
+		health:  grpchealth.NewServer(),
 	}
 }
 
+// Start binds the listener, so a busy port is reported before the service is considered started.
 func (srv *GRPCServer) Start(ctx context.Context) error {
-	s := grpc.NewServer(grpc.UnaryInterceptor(srv.RequestLogInterceptor))
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			srv.TracingUnaryInterceptor,
+			srv.RequestLogInterceptor,
+			srv.AuthUnaryInterceptor,
+			srv.RateLimitUnaryInterceptor,
+		),
+		grpc.ChainStreamInterceptor(srv.AuthStreamInterceptor, srv.RateLimitStreamInterceptor),
+	}
+
+	if srv.maxBody > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(srv.maxBody)))
+	}
+
+	if srv.tls.Enabled {
+		reloader, err := tlsconfig.NewReloader(srv.tls)
+		if err != nil {
+			return err
+		}
+
+		srv.reloader = reloader
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig("h2"))))
+	}
+
+	s := grpc.NewServer(opts...)
 
 	srv.server = s
 
 	RegisterEventServiceServer(s, srv)
+	eventv2.RegisterEventServiceServer(s, &serverV2{app: srv.app})
+	healthpb.RegisterHealthServer(s, srv.health)
 
 	addr := net.JoinHostPort(srv.host, srv.port)
 
-	go func() {
-		l, err := net.Listen("tcp", addr)
-		if err != nil {
-			srv.logger.Error(err)
-
-			return
-		}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv.listener = l
 
-		err = s.Serve(l)
-		if err != nil {
-			srv.logger.Error(err)
-		}
-	}()
+	go srv.watchHealth(ctx)
 
 	srv.logger.Info("GRPC server starting on tcp://" + addr)
 
-	<-ctx.Done()
 	return nil
 }
 
-func (srv *GRPCServer) Stop() {
-	srv.server.GracefulStop()
+// Serve handles the connections until Stop is called.
+func (srv *GRPCServer) Serve(_ context.Context) error {
+	return srv.server.Serve(srv.listener)
+}
+
+// SetLimits changes the rate limit of the running server, the body size limit requires a restart.
+func (srv *GRPCServer) SetLimits(limits config.Limits) {
+	srv.limiter.SetLimits(limits.RPS, limits.Burst)
+}
+
+// ReloadTLS re-reads the certificate files, new handshakes use them immediately.
+func (srv *GRPCServer) ReloadTLS() error {
+	if srv.reloader == nil {
+		return nil
+	}
+
+	return srv.reloader.Reload()
+}
+
+// watchHealth keeps the grpc.health.v1 status of the server and the event service in sync with the checks.
+func (srv *GRPCServer) watchHealth(ctx context.Context) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if !srv.checker.Ready(ctx) {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		srv.health.SetServingStatus("", status)
+		srv.health.SetServingStatus(EventService_ServiceDesc.ServiceName, status)
+		srv.health.SetServingStatus(eventv2.EventService_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop waits for the active calls until the ctx deadline, then closes the remaining connections.
+func (srv *GRPCServer) Stop(ctx context.Context) error {
+	srv.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		srv.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		srv.server.Stop()
+
+		return ctx.Err()
+	}
 }
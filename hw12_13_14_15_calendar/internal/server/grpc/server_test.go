@@ -10,6 +10,8 @@ import (
 	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Log struct{}
@@ -24,7 +26,7 @@ func prepareServer() *GRPCServer {
 	logg := &Log{}
 
 	calendar := app.New(memorystorage.New(), cfg)
-	return NewGRPCServer(logg, calendar, cfg)
+	return NewGRPCServer(logg, calendar, nil, nil, cfg)
 }
 
 func TestServer(t *testing.T) {
@@ -86,7 +88,7 @@ func TestServer(t *testing.T) {
 					WhenToNotify:  "2010-05-12T10:10:20Z",
 				},
 				result: 0,
-				err:    "event already exist",
+				err:    "rpc error: code = AlreadyExists desc = event already exists",
 			},
 			{
 				event: &Event{
@@ -187,7 +189,7 @@ func TestServer(t *testing.T) {
 					WhenToNotify:  "2010-05-12T10:10:20Z",
 				},
 				result: 0,
-				err:    "event not found",
+				err:    "rpc error: code = NotFound desc = event not found",
 			},
 			{
 				event: &Event{
@@ -268,7 +270,7 @@ func TestServer(t *testing.T) {
 			{
 				id:     "e13b0eb3-4b87-41e6-bf29-e2e47b9dcdd8",
 				result: 0,
-				err:    `event not found`,
+				err:    "rpc error: code = NotFound desc = event not found",
 			},
 			{
 				id:     "872e211d-4f73-4564-816d-adcfd77a2450",
@@ -306,4 +308,29 @@ func TestServer(t *testing.T) {
 			require.Equal(t, resp.Result, test.result)
 		}
 	})
+	t.Run("get test", func(t *testing.T) {
+		s := prepareServer()
+
+		event := &Event{
+			Id:            "872e211d-4f73-4564-816d-adcfd77a2450",
+			Title:         "test get",
+			DatetimeStart: "2010-05-12T10:10:20Z",
+			DatetimeEnd:   "2010-05-12T11:10:20Z",
+			Description:   "desc",
+			UserId:        "9a66db8d-5714-4276-b860-852d888c95a9",
+			WhenToNotify:  "2010-05-12T10:00:20Z",
+		}
+
+		_, err := s.Create(context.Background(), event)
+		require.NoError(t, err)
+
+		got, err := s.Get(context.Background(), &GetRequest{Id: event.Id})
+		require.NoError(t, err)
+		require.Equal(t, event.Title, got.Title)
+		require.Equal(t, event.DatetimeEnd, got.DatetimeEnd)
+		require.Equal(t, event.WhenToNotify, got.WhenToNotify)
+
+		_, err = s.Get(context.Background(), &GetRequest{Id: "e13b0eb3-4b87-41e6-bf29-e2e47b9dcdd8"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
 }
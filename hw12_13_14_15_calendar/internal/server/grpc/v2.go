@@ -0,0 +1,192 @@
+package internalgrpc
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/eventv2"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// serverV2 is the version 2 of the event service, registered next to the version 1 on the same server.
+type serverV2 struct {
+	eventv2.UnimplementedEventServiceServer
+	app Application
+}
+
+func (s *serverV2) Create(ctx context.Context, request *eventv2.Event) (*eventv2.Event, error) {
+	event, err := fromV2(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Id == "" {
+		event.ID = uuid.New()
+	}
+
+	if err := s.app.AddEvent(ctx, event); err != nil {
+		return nil, appError(err)
+	}
+
+	return toV2(event), nil
+}
+
+func (s *serverV2) Update(ctx context.Context, request *eventv2.Event) (*eventv2.Event, error) {
+	if request.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	event, err := fromV2(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.app.ChangeEvent(ctx, event); err != nil {
+		return nil, appError(err)
+	}
+
+	// The stored event is returned, the storage keeps the notified flag the request does not carry.
+	stored, err := s.app.GetEvent(ctx, request.Id)
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	return toV2(stored), nil
+}
+
+func (s *serverV2) Delete(ctx context.Context, request *eventv2.DeleteRequest) (*emptypb.Empty, error) {
+	if err := s.app.DeleteEvent(ctx, request.Id); err != nil {
+		return nil, appError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *serverV2) Get(ctx context.Context, request *eventv2.GetRequest) (*eventv2.Event, error) {
+	event, err := s.app.GetEvent(ctx, request.Id)
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	return toV2(event), nil
+}
+
+func (s *serverV2) List(ctx context.Context, request *eventv2.ListRequest) (*eventv2.ListResponse, error) {
+	start, err := timestamp("start", request.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := timestamp("end", request.End)
+	if err != nil {
+		return nil, err
+	}
+
+	if !end.After(start) {
+		return nil, status.Error(codes.InvalidArgument, "the end must be after the start")
+	}
+
+	result, err := s.app.FindEventsByPeriod(ctx, start, end)
+	if err != nil {
+		return nil, appError(err)
+	}
+
+	events := make([]storage.Event, 0, len(result))
+	for _, event := range result {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].DatetimeStart.Before(events[j].DatetimeStart)
+	})
+
+	response := &eventv2.ListResponse{Events: make([]*eventv2.Event, 0, len(events))}
+	for _, event := range events {
+		response.Events = append(response.Events, toV2(event))
+	}
+
+	return response, nil
+}
+
+// fromV2 checks the request event, an empty id is left to the caller.
+func fromV2(e *eventv2.Event) (storage.Event, error) {
+	event := storage.Event{Title: e.Title, Description: e.Description, Channel: e.Channel}
+
+	if e.Channel != "" && !knownChannel(e.Channel) {
+		return event, status.Errorf(codes.InvalidArgument, "bad channel %q, one of %s expected",
+			e.Channel, strings.Join(config.SenderChannels, ", "))
+	}
+
+	var err error
+	if e.Id != "" {
+		if event.ID, err = uuid.Parse(e.Id); err != nil {
+			return event, status.Errorf(codes.InvalidArgument, "bad id: %s", err)
+		}
+	}
+
+	if event.UserID, err = uuid.Parse(e.UserId); err != nil {
+		return event, status.Errorf(codes.InvalidArgument, "bad user_id: %s", err)
+	}
+
+	if event.DatetimeStart, err = timestamp("start", e.Start); err != nil {
+		return event, err
+	}
+
+	if event.DatetimeEnd, err = timestamp("end", e.End); err != nil {
+		return event, err
+	}
+
+	before := time.Duration(0)
+	if e.NotifyBefore != nil {
+		if err := e.NotifyBefore.CheckValid(); err != nil {
+			return event, status.Errorf(codes.InvalidArgument, "bad notify_before: %s", err)
+		}
+		before = e.NotifyBefore.AsDuration()
+	}
+	event.WhenToNotify = event.DatetimeStart.Add(-before)
+
+	return event, nil
+}
+
+func knownChannel(channel string) bool {
+	for _, known := range config.SenderChannels {
+		if channel == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+func timestamp(name string, t *timestamppb.Timestamp) (time.Time, error) {
+	if t == nil {
+		return time.Time{}, status.Errorf(codes.InvalidArgument, "%s is required", name)
+	}
+
+	if err := t.CheckValid(); err != nil {
+		return time.Time{}, status.Errorf(codes.InvalidArgument, "bad %s: %s", name, err)
+	}
+
+	return t.AsTime(), nil
+}
+
+func toV2(event storage.Event) *eventv2.Event {
+	return &eventv2.Event{
+		Id:           event.ID.String(),
+		Title:        event.Title,
+		Start:        timestamppb.New(event.DatetimeStart),
+		End:          timestamppb.New(event.DatetimeEnd),
+		Description:  event.Description,
+		UserId:       event.UserID.String(),
+		NotifyBefore: durationpb.New(event.DatetimeStart.Sub(event.WhenToNotify)),
+		Channel:      event.Channel,
+	}
+}
@@ -0,0 +1,88 @@
+package internalgrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc/eventv2"
+	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestServerV2(t *testing.T) {
+	cfg := &config.Config{GRPCServer: config.GRPCServer{Host: "127.0.0.1", Port: "0"}}
+	srv := NewGRPCServer(&Log{}, app.New(memorystorage.New(), cfg), nil, nil, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, srv.Start(ctx))
+	go func() { _ = srv.Serve(ctx) }()
+	defer srv.Stop(ctx)
+
+	conn, err := grpc.Dial(srv.listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	v1 := NewEventServiceClient(conn)
+	v2 := eventv2.NewEventServiceClient(conn)
+
+	start := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	created, err := v2.Create(ctx, &eventv2.Event{
+		Title:        "standup",
+		Start:        timestamppb.New(start),
+		End:          timestamppb.New(start.Add(15 * time.Minute)),
+		UserId:       "9a66db8d-5714-4276-b860-852d888c95a9",
+		NotifyBefore: durationpb.New(10 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.Id)
+	require.Equal(t, 10*time.Minute, created.NotifyBefore.AsDuration())
+
+	// Both versions share the application, v1 sees the reminder time the duration stands for.
+	old, err := v1.Get(ctx, &GetRequest{Id: created.Id})
+	require.NoError(t, err)
+	require.Equal(t, "2026-10-19T09:50:00Z", old.WhenToNotify)
+
+	created.Title = "retro"
+	created.Channel = "webhook"
+	updated, err := v2.Update(ctx, created)
+	require.NoError(t, err)
+	require.Equal(t, "retro", updated.Title)
+	require.Equal(t, "webhook", updated.Channel)
+
+	stored, err := v2.Get(ctx, &eventv2.GetRequest{Id: created.Id})
+	require.NoError(t, err)
+	require.True(t, proto.Equal(stored, updated))
+
+	created.Channel = "pigeon"
+	_, err = v2.Update(ctx, created)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	list, err := v2.List(ctx, &eventv2.ListRequest{
+		Start: timestamppb.New(start.Add(-time.Hour)),
+		End:   timestamppb.New(start.Add(time.Hour)),
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Events, 1)
+	require.True(t, list.Events[0].Start.AsTime().Equal(start))
+
+	_, err = v2.Create(ctx, &eventv2.Event{Title: "no start", UserId: created.UserId})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = v2.Delete(ctx, &eventv2.DeleteRequest{Id: created.Id})
+	require.NoError(t, err)
+
+	_, err = v2.Get(ctx, &eventv2.GetRequest{Id: created.Id})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -1,8 +1,21 @@
 package internalhttp
 
 import (
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ResponseWriter struct {
@@ -12,6 +25,7 @@ type ResponseWriter struct {
 
 func (rw *ResponseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
 }
 
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
@@ -23,5 +37,111 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 		s.logger.LogHTTPRequest(r, duration, rw.statusCode)
+		metrics.ObserveHTTPRequest(routeName(r), rw.statusCode, duration)
+	})
+}
+
+// tracingMiddleware continues the trace of the client, if any, and opens a server span per request.
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeName(r)
+		ctx, span := otel.Tracer(tracing.InstrumentationName).Start(
+			ctx,
+			route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("calendar", route, r)...),
+		)
+		defer span.End()
+
+		rw := &ResponseWriter{w, http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(rw.statusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(rw.statusCode))
+	})
+}
+
+// routeName is the path template of the matched route, so metric labels do not grow with ids and dates.
+func routeName(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unmatched"
+	}
+
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return "unmatched"
+	}
+
+	return r.Method + " " + tpl
+}
+
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		cred := auth.Credentials{
+			APIKey: r.Header.Get("X-API-Key"),
+		}
+
+		if token := r.Header.Get("Authorization"); strings.HasPrefix(token, "Bearer ") {
+			cred.Token = strings.TrimPrefix(token, "Bearer ")
+		}
+
+		userID, err := s.auth.Verify(cred)
+		if err != nil {
+			// A failed attempt costs the client IP a token, so guessing the credentials is rate limited too.
+			if !s.throttle(w, r) {
+				s.message(http.StatusUnauthorized, "unauthorized", w)
+			}
+			s.logger.Info("unauthorized request: ", err)
+
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.ContextWithUserID(r.Context(), userID)))
+	})
+}
+
+// clientKey identifies the client for rate limiting: the authenticated user, otherwise the remote IP.
+func clientKey(r *http.Request) string {
+	if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+		return "user:" + userID.String()
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return "ip:" + ip
+}
+
+// throttle answers 429 with Retry-After and reports true when the client is over the limit.
+func (s *Server) throttle(w http.ResponseWriter, r *http.Request) bool {
+	ok, wait := s.limiter.Allow(clientKey(r))
+	if ok {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+	s.message(http.StatusTooManyRequests, "too many requests", w)
+
+	return true
+}
+
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.throttle(w, r) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
 	})
 }
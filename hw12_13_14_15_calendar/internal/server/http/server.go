@@ -3,14 +3,20 @@ package internalhttp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/health"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/ratelimit"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tlsconfig"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
@@ -22,18 +28,26 @@ type Logger interface {
 }
 
 type Server struct {
-	host   string
-	port   string
-	logger Logger
-	app    Application
-	server *http.Server
+	host     string
+	port     string
+	tls      config.TLS
+	reloader *tlsconfig.Reloader
+	logger   Logger
+	app      Application
+	auth     auth.Verifier
+	limiter  *ratelimit.Limiter
+	maxBody  int64
+	checker  *health.Checker
+	server   *http.Server
+	listener net.Listener
 }
 
 type Application interface {
-	CreateEvent(id, title, start, end, desc, userID, when string) error
-	UpdateEvent(id, title, start, end, desc, userID, when string) error
-	DeleteEvent(id string) error
-	FindEventsByPeriod(start, end time.Time) (map[uuid.UUID]storage.Event, error)
+	CreateEvent(ctx context.Context, id, title, start, end, desc, userID, when string) error
+	UpdateEvent(ctx context.Context, id, title, start, end, desc, userID, when string) error
+	DeleteEvent(ctx context.Context, id string) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	FindEventsByPeriod(ctx context.Context, start, end time.Time) (map[uuid.UUID]storage.Event, error)
 }
 
 type EventRequest struct {
@@ -57,13 +71,46 @@ const (
 	month = "month"
 )
 
-func NewServer(logger Logger, app Application, cfg *config.Config) *Server {
+const defaultMaxBodyBytes = 1 << 20
+
+var errBodyTooLarge = errors.New("request body too large")
+
+func NewServer(
+	logger Logger,
+	app Application,
+	verifier auth.Verifier,
+	checker *health.Checker,
+	cfg *config.Config,
+) *Server {
+	maxBody := cfg.Limits.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = defaultMaxBodyBytes
+	}
+
 	return &Server{
-		host:   cfg.Server.Host,
-		port:   cfg.Server.Port,
-		logger: logger,
-		app:    app,
+		host:    cfg.Server.Host,
+		port:    cfg.Server.Port,
+		tls:     cfg.Server.TLS,
+		logger:  logger,
+		app:     app,
+		auth:    verifier,
+		limiter: ratelimit.New(cfg.Limits.RPS, cfg.Limits.Burst),
+		maxBody: maxBody,
+		checker: checker,
+	}
+}
+
+func (s *Server) readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, s.maxBody+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > s.maxBody {
+		return nil, errBodyTooLarge
 	}
+
+	return body, nil
 }
 
 func (s *Server) pingHandler(w http.ResponseWriter, _ *http.Request) {
@@ -71,7 +118,12 @@ func (s *Server) pingHandler(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (s *Server) createEventHandler(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	body, err := s.readBody(r)
+	if errors.Is(err, errBodyTooLarge) {
+		s.message(http.StatusRequestEntityTooLarge, err.Error(), w)
+
+		return
+	}
 	if err != nil {
 		s.message(http.StatusBadRequest, "failed to read request body", w)
 
@@ -87,10 +139,9 @@ func (s *Server) createEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.app.CreateEvent(data.ID, data.Title, data.Start, data.End, data.Desc, data.UserID, data.When)
+	err = s.app.CreateEvent(r.Context(), data.ID, data.Title, data.Start, data.End, data.Desc, data.UserID, data.When)
 	if err != nil {
-		s.message(http.StatusInternalServerError, "internal server error", w)
-		s.logger.Error(err)
+		s.appError(err, w)
 
 		return
 	}
@@ -99,7 +150,12 @@ func (s *Server) createEventHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) updateEventByGUID(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
+	body, err := s.readBody(r)
+	if errors.Is(err, errBodyTooLarge) {
+		s.message(http.StatusRequestEntityTooLarge, err.Error(), w)
+
+		return
+	}
 	if err != nil {
 		s.message(http.StatusBadRequest, "failed to read request body", w)
 
@@ -115,10 +171,9 @@ func (s *Server) updateEventByGUID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.app.UpdateEvent(data.ID, data.Title, data.Start, data.End, data.Desc, data.UserID, data.When)
+	err = s.app.UpdateEvent(r.Context(), data.ID, data.Title, data.Start, data.End, data.Desc, data.UserID, data.When)
 	if err != nil {
-		s.message(http.StatusInternalServerError, "internal server error", w)
-		s.logger.Error(err)
+		s.appError(err, w)
 
 		return
 	}
@@ -130,7 +185,25 @@ func (s *Server) deleteEventByGUID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	eventID := vars["eventID"]
 
-	err := s.app.DeleteEvent(eventID)
+	err := s.app.DeleteEvent(r.Context(), eventID)
+	if err != nil {
+		s.appError(err, w)
+
+		return
+	}
+
+	s.message(http.StatusOK, fmt.Sprintf("event %s was deleted", eventID), w)
+}
+
+func (s *Server) getEventByGUID(w http.ResponseWriter, r *http.Request) {
+	event, err := s.app.GetEvent(r.Context(), mux.Vars(r)["eventID"])
+	if err != nil {
+		s.appError(err, w)
+
+		return
+	}
+
+	res, err := json.Marshal(event)
 	if err != nil {
 		s.message(http.StatusInternalServerError, "internal server error", w)
 		s.logger.Error(err)
@@ -138,7 +211,10 @@ func (s *Server) deleteEventByGUID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.message(http.StatusOK, fmt.Sprintf("event %s was deleted", eventID), w)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(res); err != nil {
+		s.logger.Error(err)
+	}
 }
 
 func (s *Server) getEventsByPeriod(w http.ResponseWriter, r *http.Request) {
@@ -169,7 +245,7 @@ func (s *Server) getEventsByPeriod(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := s.app.FindEventsByPeriod(dateStart, dateEnd)
+	result, err := s.app.FindEventsByPeriod(r.Context(), dateStart, dateEnd)
 	if err != nil {
 		s.message(http.StatusInternalServerError, "internal server error", w)
 		s.logger.Error(err)
@@ -192,6 +268,29 @@ func (s *Server) getEventsByPeriod(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) appError(err error, w http.ResponseWriter) {
+	if errors.Is(err, app.ErrForbidden) {
+		s.message(http.StatusForbidden, "forbidden", w)
+
+		return
+	}
+
+	if errors.Is(err, storage.ErrEventNotFound) {
+		s.message(http.StatusNotFound, err.Error(), w)
+
+		return
+	}
+
+	if errors.Is(err, storage.ErrEventExists) {
+		s.message(http.StatusConflict, storage.ErrEventExists.Error(), w)
+
+		return
+	}
+
+	s.message(http.StatusInternalServerError, "internal server error", w)
+	s.logger.Error(err)
+}
+
 func (s *Server) message(status int, message string, w http.ResponseWriter) {
 	res, err := json.Marshal(TypicalResponse{
 		Status:  status,
@@ -211,38 +310,89 @@ func (s *Server) message(status int, message string, w http.ResponseWriter) {
 	}
 }
 
-func (s *Server) Start(ctx context.Context) error {
-	addr := net.JoinHostPort(s.host, s.port)
-
+// Handler routes the API requests, Start serves it.
+func (s *Server) Handler() http.Handler {
 	r := mux.NewRouter()
 	r.HandleFunc("/", s.pingHandler)
-	r.HandleFunc("/events/{period}/{YYYY}/{MM}/{DD}", s.getEventsByPeriod).Methods("GET")
-	r.HandleFunc("/events/create", s.createEventHandler).Methods("POST")
-	r.HandleFunc("/events/{eventID}", s.updateEventByGUID).Methods("PATCH")
-	r.HandleFunc("/events/{eventID}", s.deleteEventByGUID).Methods("DELETE")
+	r.HandleFunc("/healthz", health.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", s.checker.ReadinessHandler).Methods("GET")
+
+	events := r.PathPrefix("/events").Subrouter()
+	events.HandleFunc("/{period}/{YYYY}/{MM}/{DD}", s.getEventsByPeriod).Methods("GET")
+	events.HandleFunc("/create", s.createEventHandler).Methods("POST")
+	events.HandleFunc("/{eventID}", s.getEventByGUID).Methods("GET")
+	events.HandleFunc("/{eventID}", s.updateEventByGUID).Methods("PATCH")
+	events.HandleFunc("/{eventID}", s.deleteEventByGUID).Methods("DELETE")
+	events.Use(s.authMiddleware, s.rateLimitMiddleware)
+
+	r.Use(s.tracingMiddleware, s.loggingMiddleware)
+
+	return r
+}
 
-	r.Use(s.loggingMiddleware)
+// Start binds the listener, so a busy port is reported before the service is considered started.
+func (s *Server) Start(_ context.Context) error {
+	addr := net.JoinHostPort(s.host, s.port)
 
-	server := &http.Server{
+	s.server = &http.Server{
 		Addr:    addr,
-		Handler: r,
+		Handler: s.Handler(),
 	}
 
-	s.server = server
-
-	go func() {
-		err := server.ListenAndServe()
+	scheme := "http"
+	if s.tls.Enabled {
+		reloader, err := tlsconfig.NewReloader(s.tls)
 		if err != nil {
-			s.logger.Error(err)
+			return err
 		}
-	}()
 
-	s.logger.Info("server starting on http://" + addr)
+		s.reloader = reloader
+		s.server.TLSConfig = reloader.ServerConfig("h2", "http/1.1")
+		scheme = "https"
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+
+	s.logger.Info("server starting on " + scheme + "://" + addr)
 
-	<-ctx.Done()
 	return nil
 }
 
+// Serve handles the connections until Stop is called.
+func (s *Server) Serve(_ context.Context) error {
+	var err error
+	if s.tls.Enabled {
+		err = s.server.ServeTLS(s.listener, "", "")
+	} else {
+		err = s.server.Serve(s.listener)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// SetLimits changes the rate limit of the running server, the body size limit requires a restart.
+func (s *Server) SetLimits(limits config.Limits) {
+	s.limiter.SetLimits(limits.RPS, limits.Burst)
+}
+
+// ReloadTLS re-reads the certificate files, new handshakes use them immediately.
+func (s *Server) ReloadTLS() error {
+	if s.reloader == nil {
+		return nil
+	}
+
+	return s.reloader.Reload()
+}
+
+// Stop waits for the active requests until the ctx deadline.
 func (s *Server) Stop(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/auth"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
 	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
@@ -38,7 +39,7 @@ func prepareServer() *Server {
 	logg := &Log{}
 
 	calendar := app.New(memorystorage.New(), cfg)
-	return NewServer(logg, calendar, cfg)
+	return NewServer(logg, calendar, nil, nil, cfg)
 }
 
 func checkResponse(t *testing.T, response *http.Response, message string) {
@@ -128,7 +129,7 @@ func TestServer(t *testing.T) {
 		require.Nil(t, err)
 
 		defer respTwo.Body.Close()
-		checkResponse(t, respTwo, `{"Status":500,"Message":"internal server error"}`)
+		checkResponse(t, respTwo, `{"Status":409,"Message":"event already exists"}`)
 	})
 
 	t.Run("updateEventByGUID bad json", func(t *testing.T) {
@@ -207,7 +208,7 @@ func TestServer(t *testing.T) {
 		respDelete, err := http.Get(serv.URL + "/delete/26109d4b-1d69-4e32-a189-7ccab6c4230b")
 		require.Nil(t, err)
 
-		checkResponse(t, respDelete, `{"Status":500,"Message":"internal server error"}`)
+		checkResponse(t, respDelete, `{"Status":404,"Message":"event not found"}`)
 
 		deleted, err := http.Get(serv.URL + "/delete/" + event.ID)
 		require.Nil(t, err)
@@ -215,6 +216,40 @@ func TestServer(t *testing.T) {
 		checkResponse(t, deleted, `{"Status":200,"Message":"event `+event.ID+` was deleted"}`)
 	})
 
+	t.Run("test getEventByGUID", func(t *testing.T) {
+		s := prepareServer()
+
+		router := mux.NewRouter()
+
+		router.HandleFunc("/create", s.createEventHandler)
+		router.HandleFunc("/{eventID}", s.getEventByGUID)
+
+		serv := httptest.NewServer(router)
+		defer serv.Close()
+
+		res, err := json.Marshal(event)
+		require.Nil(t, err)
+
+		resp, err := http.Post(serv.URL+"/create", "application/json", bytes.NewReader(res))
+		require.Nil(t, err)
+		resp.Body.Close()
+
+		eventResponseStr, err := getEventResponse(t, event)
+		require.Nil(t, err)
+
+		found, err := http.Get(serv.URL + "/" + event.ID)
+		require.Nil(t, err)
+		defer found.Body.Close()
+
+		checkResponse(t, found, eventResponseStr)
+
+		missing, err := http.Get(serv.URL + "/26109d4b-1d69-4e32-a189-7ccab6c4230b")
+		require.Nil(t, err)
+		defer missing.Body.Close()
+
+		checkResponse(t, missing, `{"Status":404,"Message":"event not found"}`)
+	})
+
 	t.Run("test eventsByPeriod", func(t *testing.T) {
 		s := prepareServer()
 
@@ -282,4 +317,138 @@ func TestServer(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("test authMiddleware", func(t *testing.T) {
+		verifier, err := auth.New(config.Auth{
+			Enabled: true,
+			APIKeys: []config.APIKey{
+				{Key: "owner", UserID: event.UserID},
+				{Key: "stranger", UserID: "26109d4b-1d69-4e32-a189-7ccab6c4230b"},
+			},
+		})
+		require.Nil(t, err)
+
+		s := prepareServer()
+		s.auth = verifier
+
+		router := mux.NewRouter()
+		router.HandleFunc("/create", s.createEventHandler)
+		router.HandleFunc("/delete/{eventID}", s.deleteEventByGUID)
+		router.Use(s.authMiddleware)
+
+		serv := httptest.NewServer(router)
+		defer serv.Close()
+
+		res, err := json.Marshal(event)
+		require.Nil(t, err)
+
+		request := func(method, path, key string, body []byte) *http.Response {
+			req, err := http.NewRequest(method, serv.URL+path, bytes.NewReader(body))
+			require.Nil(t, err)
+
+			if key != "" {
+				req.Header.Set("X-API-Key", key)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+
+			return resp
+		}
+
+		resp := request(http.MethodPost, "/create", "", res)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":401,"Message":"unauthorized"}`)
+
+		resp = request(http.MethodPost, "/create", "bad", res)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":401,"Message":"unauthorized"}`)
+
+		resp = request(http.MethodPost, "/create", "stranger", res)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":403,"Message":"forbidden"}`)
+
+		resp = request(http.MethodPost, "/create", "owner", res)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":200,"Message":"event was created"}`)
+
+		resp = request(http.MethodDelete, "/delete/"+event.ID, "stranger", nil)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":403,"Message":"forbidden"}`)
+
+		resp = request(http.MethodDelete, "/delete/"+event.ID, "owner", nil)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":200,"Message":"event `+event.ID+` was deleted"}`)
+	})
+
+	t.Run("test limits", func(t *testing.T) {
+		cfg := &config.Config{
+			Limits: config.Limits{RPS: 1, Burst: 1, MaxBodyBytes: 16},
+		}
+		s := NewServer(&Log{}, app.New(memorystorage.New(), cfg), nil, nil, cfg)
+
+		router := mux.NewRouter()
+		router.HandleFunc("/create", s.createEventHandler)
+		router.Use(s.rateLimitMiddleware)
+
+		serv := httptest.NewServer(router)
+		defer serv.Close()
+
+		res, err := json.Marshal(event)
+		require.Nil(t, err)
+
+		resp, err := http.Post(serv.URL+"/create", "application/json", bytes.NewReader(res))
+		require.Nil(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		checkResponse(t, resp, `{"Status":413,"Message":"request body too large"}`)
+
+		resp, err = http.Post(serv.URL+"/create", "application/json", bytes.NewReader(res))
+		require.Nil(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.Equal(t, "1", resp.Header.Get("Retry-After"))
+		checkResponse(t, resp, `{"Status":429,"Message":"too many requests"}`)
+	})
+
+	t.Run("test metrics are not public", func(t *testing.T) {
+		cfg := &config.Config{}
+		s := NewServer(&Log{}, app.New(memorystorage.New(), cfg), nil, nil, cfg)
+
+		serv := httptest.NewServer(s.Handler())
+		defer serv.Close()
+
+		resp, err := http.Get(serv.URL + "/metrics")
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("test limits of failed auth", func(t *testing.T) {
+		cfg := &config.Config{Limits: config.Limits{RPS: 1, Burst: 1}}
+		s := NewServer(&Log{}, app.New(memorystorage.New(), cfg), nil, nil, cfg)
+		verifier, err := auth.New(config.Auth{
+			Enabled: true,
+			APIKeys: []config.APIKey{{Key: "owner", UserID: event.UserID}},
+		})
+		require.Nil(t, err)
+		s.auth = verifier
+
+		serv := httptest.NewServer(s.Handler())
+		defer serv.Close()
+
+		// The wrong keys are guessed from one IP, the second attempt is throttled.
+		resp, err := http.Get(serv.URL + "/events/" + event.ID)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		checkResponse(t, resp, `{"Status":401,"Message":"unauthorized"}`)
+
+		resp, err = http.Get(serv.URL + "/events/" + event.ID)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "1", resp.Header.Get("Retry-After"))
+		checkResponse(t, resp, `{"Status":429,"Message":"too many requests"}`)
+	})
 }
@@ -1,11 +1,19 @@
 package storage
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrEventNotFound is returned by every storage for a missing event.
+	ErrEventNotFound = errors.New("event not found")
+	// ErrEventExists is returned by every storage for an event added with a taken id.
+	ErrEventExists = errors.New("event already exists")
+)
+
 type Event struct {
 	ID            uuid.UUID // Уникальный идентификатор события (можно воспользоваться UUID)
 	Title         string    // Короткий текст
@@ -15,4 +23,31 @@ type Event struct {
 	UserID        uuid.UUID // ID пользователя, владельца события
 	IsNotified    bool      // Было ли отправлено уведомление
 	WhenToNotify  time.Time // За сколько времени высылать уведомление, опционально.
+	Channel       string    // Channel of the reminder, the preference of the user when empty
+}
+
+// Notification is the queue message of a reminder. DeliverAt is the time to deliver the reminder queued ahead,
+// a zero time means at once. MessageID is kept on redelivery, so the sender skips the duplicates it remembers.
+// Attempt counts the failed deliveries of the reminder.
+type Notification struct {
+	Event
+	MessageID uuid.UUID
+	DeliverAt time.Time
+	Attempt   int
+}
+
+// NewNotification is the message of an event claimed at now.
+func NewNotification(event Event, now time.Time) Notification {
+	notification := Notification{Event: event, MessageID: uuid.New()}
+	if event.WhenToNotify.After(now) {
+		notification.DeliverAt = event.WhenToNotify
+	}
+
+	return notification
+}
+
+// OutboxMessage is a claimed notification waiting to be published, it is deleted once the queue confirms it.
+type OutboxMessage struct {
+	ID      uuid.UUID
+	Payload []byte
 }
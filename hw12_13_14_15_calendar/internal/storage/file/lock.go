@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package filestorage
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes the advisory lock of the directory, so the calendar and the scheduler can share it.
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,12 @@
+package filestorage
+
+import "os"
+
+// lockFile is a no-op on Windows, the directory must not be shared by several processes there.
+func lockFile(_ *os.File, _ bool) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
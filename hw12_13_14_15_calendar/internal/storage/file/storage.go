@@ -0,0 +1,544 @@
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/google/uuid"
+)
+
+const (
+	walName      = "wal.log"
+	snapshotName = "snapshot.json"
+	lockName     = "lock"
+)
+
+var errNotConnected = errors.New("storage is not connected")
+
+type snapshot struct {
+	Seq    uint64
+	Events []storage.Event
+	Outbox []storage.OutboxMessage
+}
+
+// Storage keeps the events in memory and appends each change to a write-ahead log in the directory.
+// The log is compacted to a snapshot once it has snapshotEvery records and outgrows the last snapshot,
+// so a bulk import rewrites the snapshot only a logarithmic number of times. On start the snapshot
+// and the log are replayed. Every call catches up with the records appended by the other processes sharing the directory.
+type Storage struct {
+	dir           string
+	snapshotEvery int
+
+	mu            sync.Mutex
+	lock          *os.File
+	wal           *os.File
+	walInfo       os.FileInfo
+	offset        int64
+	seq           uint64
+	sinceSnapshot int
+	snapshotSize  int64
+	mem           *memorystorage.Storage
+}
+
+func New(dir string, snapshotEvery int) *Storage {
+	return &Storage{
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+	}
+}
+
+func (s *Storage) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func (s *Storage) Connect(_ context.Context) error {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return fmt.Errorf("create storage directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(s.path(lockName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("open lock file: %w", err)
+	}
+
+	s.mu.Lock()
+	s.lock = lock
+	s.mu.Unlock()
+
+	return s.withLock(false, func() error { return nil })
+}
+
+func (s *Storage) Ping(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return errNotConnected
+	}
+
+	_, err := os.Stat(s.path(walName))
+
+	return err
+}
+
+// Close compacts the log when it has records and releases the files.
+func (s *Storage) Close() error {
+	if err := s.withLock(true, func() error {
+		if s.sinceSnapshot > 0 {
+			return s.compact()
+		}
+
+		return nil
+	}); err != nil && !errors.Is(err, errNotConnected) {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.wal != nil {
+		err = s.wal.Close()
+		s.wal = nil
+	}
+
+	if s.lock != nil {
+		if lockErr := s.lock.Close(); err == nil {
+			err = lockErr
+		}
+		s.lock = nil
+	}
+
+	s.mem = nil
+
+	return err
+}
+
+func (s *Storage) AddEvent(_ context.Context, event storage.Event) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opAdd, Event: &event})
+	})
+}
+
+func (s *Storage) ChangeEvent(_ context.Context, id uuid.UUID, event storage.Event) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opChange, ID: id, Event: &event})
+	})
+}
+
+func (s *Storage) RemoveEvent(_ context.Context, id uuid.UUID) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opRemove, ID: id})
+	})
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id uuid.UUID) (event storage.Event, err error) {
+	err = s.withLock(false, func() error {
+		event, err = s.mem.GetEvent(ctx, id)
+
+		return err
+	})
+
+	return event, err
+}
+
+func (s *Storage) ListEventsByRange(
+	ctx context.Context,
+	p storage.DateRange,
+) (events map[uuid.UUID]storage.Event, err error) {
+	err = s.withLock(false, func() error {
+		events, err = s.mem.ListEventsByRange(ctx, p)
+
+		return err
+	})
+
+	return events, err
+}
+
+// EachEvent streams the events in the range, the directory is locked for reading meanwhile.
+func (s *Storage) EachEvent(ctx context.Context, p storage.DateRange, fn func(storage.Event) error) error {
+	return s.withLock(false, func() error {
+		return s.mem.EachEvent(ctx, p, fn)
+	})
+}
+
+func (s *Storage) RemoveOldEvents(_ context.Context, datetime time.Time) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opRemoveOld, Time: datetime})
+	})
+}
+
+func (s *Storage) ListEventsForNotification(
+	ctx context.Context,
+	datetime time.Time,
+) (events map[uuid.UUID]storage.Event, err error) {
+	err = s.withLock(false, func() error {
+		events, err = s.mem.ListEventsForNotification(ctx, datetime)
+
+		return err
+	})
+
+	return events, err
+}
+
+func (s *Storage) SetIsNotified(_ context.Context, id uuid.UUID) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opNotified, ID: id})
+	})
+}
+
+// ClaimNotifications logs the claimed reminders with their messages in one record, so a replay
+// restores the same outbox.
+func (s *Storage) ClaimNotifications(ctx context.Context, datetime, now time.Time) (count int, err error) {
+	err = s.withLock(true, func() error {
+		events, err := s.mem.ListEventsForNotification(ctx, datetime)
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		rec := record{Op: opClaim}
+		for _, event := range events {
+			notification := storage.NewNotification(event, now)
+
+			payload, err := json.Marshal(notification)
+			if err != nil {
+				return fmt.Errorf("marshal notification: %w", err)
+			}
+
+			rec.Claimed = append(rec.Claimed, claimed{
+				EventID: event.ID,
+				Message: storage.OutboxMessage{ID: notification.MessageID, Payload: payload},
+			})
+		}
+
+		count = len(rec.Claimed)
+
+		return s.write(rec)
+	})
+
+	return count, err
+}
+
+func (s *Storage) ListOutbox(ctx context.Context, limit int) (messages []storage.OutboxMessage, err error) {
+	err = s.withLock(false, func() error {
+		messages, err = s.mem.ListOutbox(ctx, limit)
+
+		return err
+	})
+
+	return messages, err
+}
+
+func (s *Storage) DeleteOutbox(_ context.Context, id uuid.UUID) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opDeleteOutbox, ID: id})
+	})
+}
+
+// AcquireLease logs the lease with its expiry, the processes sharing the directory run on the same clock.
+func (s *Storage) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (acquired bool, err error) {
+	err = s.withLock(true, func() error {
+		acquired, err = s.mem.AcquireLease(ctx, name, holder, ttl)
+		if err != nil || !acquired {
+			return err
+		}
+
+		rec := record{Op: opLease, Name: name, Holder: holder, ExpiresAt: time.Now().Add(ttl)}
+		rec.Seq = s.seq + 1
+
+		return s.logApplied(rec)
+	})
+
+	return acquired, err
+}
+
+func (s *Storage) ReleaseLease(_ context.Context, name, holder string) error {
+	return s.withLock(true, func() error {
+		return s.write(record{Op: opRelease, Name: name, Holder: holder})
+	})
+}
+
+// withLock runs fn with the directory locked and the memory caught up with the log.
+func (s *Storage) withLock(exclusive bool, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lock == nil {
+		return errNotConnected
+	}
+
+	if err := lockFile(s.lock, exclusive); err != nil {
+		return fmt.Errorf("lock storage: %w", err)
+	}
+
+	err := s.catchUp()
+	if err == nil {
+		err = fn()
+	}
+
+	if unlockErr := unlockFile(s.lock); unlockErr != nil && err == nil {
+		err = fmt.Errorf("unlock storage: %w", unlockErr)
+	}
+
+	return err
+}
+
+// catchUp replays the records appended since the last call. A log replaced by the compaction
+// of another process, or a failed write of this one, reloads everything.
+func (s *Storage) catchUp() error {
+	info, err := os.Stat(s.path(walName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat log: %w", err)
+	}
+
+	if s.wal == nil || info == nil || !os.SameFile(s.walInfo, info) {
+		return s.reload()
+	}
+
+	read, err := readRecords(s.wal, s.offset, s.replay)
+	s.offset += read
+
+	return err
+}
+
+func (s *Storage) reload() error {
+	if s.wal != nil {
+		_ = s.wal.Close()
+		s.wal = nil
+	}
+
+	s.mem = memorystorage.New()
+	s.seq = 0
+	s.offset = 0
+	s.sinceSnapshot = 0
+	s.snapshotSize = 0
+
+	if err := s.loadSnapshot(); err != nil {
+		return err
+	}
+
+	wal, err := os.OpenFile(s.path(walName), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+
+	info, err := wal.Stat()
+	if err != nil {
+		_ = wal.Close()
+
+		return fmt.Errorf("stat log: %w", err)
+	}
+
+	s.wal, s.walInfo = wal, info
+
+	read, err := readRecords(wal, 0, s.replay)
+	s.offset = read
+
+	return err
+}
+
+func (s *Storage) loadSnapshot() error {
+	data, err := os.ReadFile(s.path(snapshotName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("parse snapshot: %w", err)
+	}
+
+	ctx := context.Background()
+	for _, event := range snap.Events {
+		if err := s.mem.AddEvent(ctx, event); err != nil {
+			return fmt.Errorf("load snapshot: %w", err)
+		}
+	}
+
+	for _, message := range snap.Outbox {
+		s.mem.AddOutbox(message)
+	}
+
+	s.seq = snap.Seq
+	s.snapshotSize = int64(len(data))
+
+	return nil
+}
+
+// replay applies a record of the log unless the snapshot already has it.
+func (s *Storage) replay(rec record) error {
+	if rec.Seq <= s.seq {
+		return nil
+	}
+
+	if err := s.apply(rec); err != nil {
+		return err
+	}
+
+	s.seq = rec.Seq
+	s.sinceSnapshot++
+
+	return nil
+}
+
+func (s *Storage) apply(rec record) error {
+	ctx := context.Background()
+
+	switch rec.Op {
+	case opAdd:
+		return s.mem.AddEvent(ctx, *rec.Event)
+	case opChange:
+		return s.mem.ChangeEvent(ctx, rec.ID, *rec.Event)
+	case opRemove:
+		return s.mem.RemoveEvent(ctx, rec.ID)
+	case opRemoveOld:
+		return s.mem.RemoveOldEvents(ctx, rec.Time)
+	case opNotified:
+		return s.mem.SetIsNotified(ctx, rec.ID)
+	case opClaim:
+		for _, c := range rec.Claimed {
+			if err := s.mem.SetIsNotified(ctx, c.EventID); err != nil {
+				return err
+			}
+			s.mem.AddOutbox(c.Message)
+		}
+
+		return nil
+	case opDeleteOutbox:
+		return s.mem.DeleteOutbox(ctx, rec.ID)
+	case opLease:
+		// An expired lease is left free, the holder renews it anyway.
+		if ttl := time.Until(rec.ExpiresAt); ttl > 0 {
+			_, err := s.mem.AcquireLease(ctx, rec.Name, rec.Holder, ttl)
+
+			return err
+		}
+
+		return nil
+	case opRelease:
+		return s.mem.ReleaseLease(ctx, rec.Name, rec.Holder)
+	default:
+		return fmt.Errorf("unknown log operation %q", rec.Op)
+	}
+}
+
+// write applies the record and appends it to the log, the directory must be locked exclusively.
+func (s *Storage) write(rec record) error {
+	rec.Seq = s.seq + 1
+
+	if err := s.apply(rec); err != nil {
+		return err
+	}
+
+	return s.logApplied(rec)
+}
+
+// logApplied appends the record already applied to the memory. The memory is reloaded from the files
+// when the append fails.
+func (s *Storage) logApplied(rec record) error {
+	if err := s.append(rec); err != nil {
+		s.walInfo = nil
+
+		return err
+	}
+
+	s.seq = rec.Seq
+	s.sinceSnapshot++
+
+	if s.sinceSnapshot >= s.snapshotEvery && s.offset >= s.snapshotSize {
+		return s.compact()
+	}
+
+	return nil
+}
+
+func (s *Storage) append(rec record) error {
+	line, err := encode(rec)
+	if err != nil {
+		return err
+	}
+
+	// Drops the torn tail of a crashed write, a damaged record before others fails the read instead.
+	if err := s.wal.Truncate(s.offset); err != nil {
+		return fmt.Errorf("truncate log: %w", err)
+	}
+
+	if _, err := s.wal.WriteAt(line, s.offset); err != nil {
+		return fmt.Errorf("write log: %w", err)
+	}
+
+	if err := s.wal.Sync(); err != nil {
+		return fmt.Errorf("sync log: %w", err)
+	}
+
+	s.offset += int64(len(line))
+
+	return nil
+}
+
+// compact writes the snapshot and starts an empty log. A crash in between leaves the old log,
+// its records are skipped by their sequence numbers.
+func (s *Storage) compact() error {
+	outbox, err := s.mem.ListOutbox(context.Background(), math.MaxInt32)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snapshot{Seq: s.seq, Events: s.mem.Events(), Outbox: outbox})
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	if err := writeFile(s.path(snapshotName), data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	if err := writeFile(s.path(walName), nil); err != nil {
+		return fmt.Errorf("reset log: %w", err)
+	}
+
+	// The new log is opened by the next call, the same way as in the other processes.
+	s.walInfo = nil
+
+	return nil
+}
+
+// writeFile replaces the file atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
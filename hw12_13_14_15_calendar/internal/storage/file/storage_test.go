@@ -0,0 +1,204 @@
+package filestorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(notify time.Time) storage.Event {
+	return storage.Event{
+		ID:            uuid.New(),
+		Title:         "meeting",
+		DatetimeStart: notify.Add(time.Hour),
+		DatetimeEnd:   notify.Add(2 * time.Hour),
+		UserID:        uuid.New(),
+		WhenToNotify:  notify,
+	}
+}
+
+func openStorage(t *testing.T, dir string, snapshotEvery int) *Storage {
+	t.Helper()
+
+	s := New(dir, snapshotEvery)
+	require.Nil(t, s.Connect(context.Background()))
+
+	return s
+}
+
+func TestRecovery(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Now()
+
+	s := openStorage(t, dir, 1000)
+
+	first, second := newEvent(now.Add(-time.Minute)), newEvent(now.Add(time.Hour))
+	require.Nil(t, s.AddEvent(ctx, first))
+	require.Nil(t, s.AddEvent(ctx, second))
+	require.NotNil(t, s.AddEvent(ctx, first))
+
+	second.Title = "changed"
+	require.Nil(t, s.ChangeEvent(ctx, second.ID, second))
+
+	claimed, err := s.ClaimNotifications(ctx, now, now)
+	require.Nil(t, err)
+	require.Equal(t, 1, claimed)
+
+	outbox, err := s.ListOutbox(ctx, 10)
+	require.Nil(t, err)
+	require.Len(t, outbox, 1)
+
+	// A crash leaves the log without a snapshot and with a torn record.
+	wal, err := os.OpenFile(filepath.Join(dir, walName), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.Nil(t, err)
+	_, err = wal.WriteString(`1234 {"Seq":5,"Op":"rem`)
+	require.Nil(t, err)
+	require.Nil(t, wal.Close())
+
+	recovered := openStorage(t, dir, 1000)
+
+	event, err := recovered.GetEvent(ctx, second.ID)
+	require.Nil(t, err)
+	require.Equal(t, "changed", event.Title)
+
+	event, err = recovered.GetEvent(ctx, first.ID)
+	require.Nil(t, err)
+	require.True(t, event.IsNotified)
+
+	recoveredOutbox, err := recovered.ListOutbox(ctx, 10)
+	require.Nil(t, err)
+	require.Equal(t, outbox, recoveredOutbox)
+
+	// The torn tail is overwritten by the next record.
+	require.Nil(t, recovered.RemoveEvent(ctx, first.ID))
+	require.Nil(t, recovered.Close())
+
+	reopened := openStorage(t, dir, 1000)
+	_, err = reopened.GetEvent(ctx, first.ID)
+	require.NotNil(t, err)
+	require.Nil(t, reopened.Close())
+	require.Nil(t, s.Close())
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := openStorage(t, dir, 3)
+
+	events := make([]storage.Event, 7)
+	for i := range events {
+		events[i] = newEvent(time.Now())
+		require.Nil(t, s.AddEvent(ctx, events[i]))
+	}
+
+	// Two snapshots are taken, the log has the last record only.
+	data, err := os.ReadFile(filepath.Join(dir, walName))
+	require.Nil(t, err)
+	rec, err := decode(data)
+	require.Nil(t, err)
+	require.Equal(t, uint64(7), rec.Seq)
+
+	reopened := openStorage(t, dir, 3)
+	for _, event := range events {
+		_, err := reopened.GetEvent(ctx, event.ID)
+		require.Nil(t, err)
+	}
+	require.Nil(t, reopened.Close())
+	require.Nil(t, s.Close())
+}
+
+func TestCorruptLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := openStorage(t, dir, 1000)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, s.AddEvent(ctx, newEvent(time.Now())))
+	}
+
+	// A damaged record in the middle is not a torn tail, the records after it stay in the log.
+	path := filepath.Join(dir, walName)
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+	damaged := append([]byte(nil), data...)
+	damaged[len(data)/2] ^= 0xff
+	require.Nil(t, os.WriteFile(path, damaged, 0o600))
+
+	err = New(dir, 1000).Connect(ctx)
+	require.True(t, errors.Is(err, errCorruptLog))
+
+	after, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, damaged, after)
+}
+
+func TestCompactionBackoff(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	s := openStorage(t, dir, 1)
+	defer s.Close()
+
+	// A snapshot is written only when the log outgrows the previous one, not after every record.
+	snapshots := 0
+	for i := 0; i < 100; i++ {
+		require.Nil(t, s.AddEvent(ctx, newEvent(time.Now())))
+
+		if s.walInfo == nil {
+			snapshots++
+		}
+	}
+	require.Less(t, snapshots, 10)
+}
+
+func TestSharedDirectory(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	calendar := openStorage(t, dir, 2)
+	scheduler := openStorage(t, dir, 2)
+
+	event := newEvent(time.Now().Add(-time.Minute))
+	require.Nil(t, calendar.AddEvent(ctx, event))
+
+	claimed, err := scheduler.ClaimNotifications(ctx, time.Now(), time.Now())
+	require.Nil(t, err)
+	require.Equal(t, 1, claimed)
+
+	// The claim compacted the log, the calendar reloads it.
+	got, err := calendar.GetEvent(ctx, event.ID)
+	require.Nil(t, err)
+	require.True(t, got.IsNotified)
+
+	acquired, err := scheduler.AcquireLease(ctx, "scheduler", "first", time.Minute)
+	require.Nil(t, err)
+	require.True(t, acquired)
+
+	acquired, err = calendar.AcquireLease(ctx, "scheduler", "second", time.Minute)
+	require.Nil(t, err)
+	require.False(t, acquired)
+
+	require.Nil(t, calendar.Close())
+	require.Nil(t, scheduler.Close())
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		s := openStorage(t, t.TempDir(), 5)
+		t.Cleanup(func() { s.Close() })
+
+		return s
+	})
+}
@@ -0,0 +1,127 @@
+package filestorage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	opAdd          = "add"
+	opChange       = "change"
+	opRemove       = "remove"
+	opRemoveOld    = "remove_old"
+	opNotified     = "notified"
+	opClaim        = "claim"
+	opDeleteOutbox = "delete_outbox"
+	opLease        = "lease"
+	opRelease      = "release"
+)
+
+var (
+	errBadRecord  = errors.New("bad log record")
+	errCorruptLog = errors.New("corrupt log")
+)
+
+// claimed is a reminder moved to the outbox.
+type claimed struct {
+	EventID uuid.UUID
+	Message storage.OutboxMessage
+}
+
+// record is a change of the storage in the log. Seq grows by one with each record, so a record already
+// in the snapshot is skipped on replay.
+type record struct {
+	Seq       uint64
+	Op        string
+	ID        uuid.UUID
+	Event     *storage.Event
+	Time      time.Time
+	Claimed   []claimed
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+}
+
+// encode writes the record as a line of its CRC-32 and JSON, a torn line fails the check on replay.
+func encode(rec record) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal log record: %w", err)
+	}
+
+	line := strconv.AppendUint(nil, uint64(crc32.ChecksumIEEE(data)), 16)
+	line = append(line, ' ')
+	line = append(line, data...)
+
+	return append(line, '\n'), nil
+}
+
+func decode(line []byte) (record, error) {
+	var rec record
+
+	sep := bytes.IndexByte(line, ' ')
+	if sep < 0 {
+		return rec, errBadRecord
+	}
+
+	sum, err := strconv.ParseUint(string(line[:sep]), 16, 32)
+	if err != nil {
+		return rec, errBadRecord
+	}
+
+	data := bytes.TrimSuffix(line[sep+1:], []byte{'\n'})
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return rec, errBadRecord
+	}
+
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, errBadRecord
+	}
+
+	return rec, nil
+}
+
+// readRecords calls apply for the complete records of r from the offset on and returns the length read up to
+// the last of them. A bad last record is the torn tail of a crashed write and ends the log, the next append
+// overwrites it. A bad record followed by others is damage an append must not cut away, so it fails the read.
+func readRecords(r io.ReaderAt, from int64, apply func(rec record) error) (int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(r, from, math.MaxInt64-from))
+
+	var read int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return read, nil
+		}
+		if err != nil {
+			return read, fmt.Errorf("read log: %w", err)
+		}
+
+		rec, err := decode(line)
+		if errors.Is(err, errBadRecord) {
+			if next, _ := reader.Peek(1); len(next) > 0 {
+				return read, fmt.Errorf("%w: the record at offset %d is damaged and more records follow it, "+
+					"restore the log from a backup or cut it at the offset", errCorruptLog, from+read)
+			}
+
+			return read, nil
+		}
+
+		if err := apply(rec); err != nil {
+			return read, fmt.Errorf("replay record %d: %w", rec.Seq, err)
+		}
+
+		read += int64(len(line))
+	}
+}
@@ -2,35 +2,99 @@ package initstorage
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	filestorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/file"
 	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
 	sqlstorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/sql"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/transfer"
 )
 
-func getDsn(s config.SQLDatabase) string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", s.User, s.Password, s.Host, s.Port, s.Name)
+func GetDsn(s config.SQLDatabase) string {
+	if s.Driver == sqlstorage.SQLite.Driver {
+		return sqliteDsn(s)
+	}
+
+	query := url.Values{}
+
+	sslMode := s.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	query.Set("sslmode", sslMode)
+
+	if s.SSLRootCert != "" {
+		query.Set("sslrootcert", s.SSLRootCert)
+	}
+
+	if s.SSLCert != "" {
+		query.Set("sslcert", s.SSLCert)
+		query.Set("sslkey", s.SSLKey)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(s.User, s.Password),
+		Host:     net.JoinHostPort(s.Host, s.Port),
+		Path:     "/" + s.Name,
+		RawQuery: query.Encode(),
+	}
+
+	return dsn.String()
+}
+
+// sqliteDsn opens the database file in the WAL mode, so the readers do not block the writer,
+// and starts the transactions with the write lock, so concurrent writers wait instead of failing.
+func sqliteDsn(s config.SQLDatabase) string {
+	query := url.Values{}
+	query.Set("_busy_timeout", "5000")
+	query.Set("_journal_mode", "WAL")
+	query.Set("_txlock", "immediate")
+
+	return "file:" + s.Name + "?" + query.Encode()
 }
 
 func NewStorage(cfg *config.Config) (app.Storage, error) {
 	switch cfg.DB.Type {
 	case "mem":
-		return memorystorage.New(), nil
+		return appStorage{memorystorage.New(), "memory"}, nil
 	case "sql":
-		return sqlstorage.New(cfg, getDsn(cfg.DB.SQL)), nil
+		system := sqlstorage.DialectFor(cfg.DB.SQL.Driver).System
+		return appStorage{sqlstorage.New(cfg, GetDsn(cfg.DB.SQL)), system}, nil
+	case "file":
+		return appStorage{filestorage.New(cfg.DB.File.Path, cfg.DB.File.SnapshotEvery), "file"}, nil
 	}
 
 	return nil, fmt.Errorf("unknown database type: %q", cfg.DB.Type)
 }
 
 func NewSchedulerStorage(cfg *config.Config) (scheduler.Storage, error) {
+	switch cfg.DB.Type {
+	case "mem":
+		return schedulerStorage{memorystorage.New(), "memory"}, nil
+	case "sql":
+		system := sqlstorage.DialectFor(cfg.DB.SQL.Driver).System
+		return schedulerStorage{sqlstorage.New(cfg, GetDsn(cfg.DB.SQL)), system}, nil
+	case "file":
+		return schedulerStorage{filestorage.New(cfg.DB.File.Path, cfg.DB.File.SnapshotEvery), "file"}, nil
+	}
+
+	return nil, fmt.Errorf("unknown database type: %q", cfg.DB.Type)
+}
+
+// NewTransferStorage returns the storage for the export and import, it is not traced nor measured.
+func NewTransferStorage(cfg *config.Config) (transfer.Storage, error) {
 	switch cfg.DB.Type {
 	case "mem":
 		return memorystorage.New(), nil
 	case "sql":
-		return sqlstorage.New(cfg, getDsn(cfg.DB.SQL)), nil
+		return sqlstorage.New(cfg, GetDsn(cfg.DB.SQL)), nil
+	case "file":
+		return filestorage.New(cfg.DB.File.Path, cfg.DB.File.SnapshotEvery), nil
 	}
 
 	return nil, fmt.Errorf("unknown database type: %q", cfg.DB.Type)
@@ -0,0 +1,124 @@
+package initstorage
+
+import (
+	"context"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/tracing"
+	"github.com/google/uuid"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+// observe opens a span of the storage operation, the returned func closes it and records the latency.
+func observe(ctx context.Context, system, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "storage."+operation,
+		semconv.DBSystemKey.String(system),
+		semconv.DBOperationKey.String(operation),
+	)
+
+	return ctx, func(err error) {
+		metrics.ObserveStorage(operation, start, err)
+		tracing.End(span, err)
+	}
+}
+
+// appStorage traces and measures the API storage operations.
+type appStorage struct {
+	app.Storage
+	system string
+}
+
+func (s appStorage) AddEvent(ctx context.Context, event storage.Event) (err error) {
+	ctx, done := observe(ctx, s.system, "add_event")
+	defer func() { done(err) }()
+
+	return s.Storage.AddEvent(ctx, event)
+}
+
+func (s appStorage) ChangeEvent(ctx context.Context, id uuid.UUID, event storage.Event) (err error) {
+	ctx, done := observe(ctx, s.system, "change_event")
+	defer func() { done(err) }()
+
+	return s.Storage.ChangeEvent(ctx, id, event)
+}
+
+func (s appStorage) RemoveEvent(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, done := observe(ctx, s.system, "remove_event")
+	defer func() { done(err) }()
+
+	return s.Storage.RemoveEvent(ctx, id)
+}
+
+func (s appStorage) GetEvent(ctx context.Context, id uuid.UUID) (event storage.Event, err error) {
+	ctx, done := observe(ctx, s.system, "get_event")
+	defer func() { done(err) }()
+
+	return s.Storage.GetEvent(ctx, id)
+}
+
+func (s appStorage) ListEventsByRange(
+	ctx context.Context,
+	p storage.DateRange,
+) (events map[uuid.UUID]storage.Event, err error) {
+	ctx, done := observe(ctx, s.system, "list_events_by_range")
+	defer func() { done(err) }()
+
+	return s.Storage.ListEventsByRange(ctx, p)
+}
+
+// schedulerStorage traces and measures the scheduler storage operations.
+type schedulerStorage struct {
+	scheduler.Storage
+	system string
+}
+
+func (s schedulerStorage) RemoveOldEvents(ctx context.Context, datetime time.Time) (err error) {
+	ctx, done := observe(ctx, s.system, "remove_old_events")
+	defer func() { done(err) }()
+
+	return s.Storage.RemoveOldEvents(ctx, datetime)
+}
+
+func (s schedulerStorage) ClaimNotifications(ctx context.Context, datetime, now time.Time) (claimed int, err error) {
+	ctx, done := observe(ctx, s.system, "claim_notifications")
+	defer func() { done(err) }()
+
+	return s.Storage.ClaimNotifications(ctx, datetime, now)
+}
+
+func (s schedulerStorage) ListOutbox(ctx context.Context, limit int) (messages []storage.OutboxMessage, err error) {
+	ctx, done := observe(ctx, s.system, "list_outbox")
+	defer func() { done(err) }()
+
+	return s.Storage.ListOutbox(ctx, limit)
+}
+
+func (s schedulerStorage) DeleteOutbox(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, done := observe(ctx, s.system, "delete_outbox")
+	defer func() { done(err) }()
+
+	return s.Storage.DeleteOutbox(ctx, id)
+}
+
+func (s schedulerStorage) AcquireLease(
+	ctx context.Context,
+	name, holder string,
+	ttl time.Duration,
+) (acquired bool, err error) {
+	ctx, done := observe(ctx, s.system, "acquire_lease")
+	defer func() { done(err) }()
+
+	return s.Storage.AcquireLease(ctx, name, holder, ttl)
+}
+
+func (s schedulerStorage) ReleaseLease(ctx context.Context, name, holder string) (err error) {
+	ctx, done := observe(ctx, s.system, "release_lease")
+	defer func() { done(err) }()
+
+	return s.Storage.ReleaseLease(ctx, name, holder)
+}
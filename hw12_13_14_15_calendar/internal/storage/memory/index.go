@@ -0,0 +1,213 @@
+package memorystorage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// node holds the IDs of the events sharing a time.
+type node struct {
+	at          int64 // unix nanoseconds
+	ids         map[uuid.UUID]struct{}
+	priority    uint32
+	left, right *node
+}
+
+// timeIndex keeps the event IDs ordered by a time in a treap, so a range is walked in O(log n + k)
+// instead of scanning all the events.
+type timeIndex struct {
+	root *node
+	seed uint32
+}
+
+// priority is a xorshift sequence, the treap only needs the priorities spread, not unpredictable.
+func (idx *timeIndex) priority() uint32 {
+	if idx.seed == 0 {
+		idx.seed = 2463534242
+	}
+
+	idx.seed ^= idx.seed << 13
+	idx.seed ^= idx.seed >> 17
+	idx.seed ^= idx.seed << 5
+
+	return idx.seed
+}
+
+func (idx *timeIndex) insert(at time.Time, id uuid.UUID) {
+	key := at.UnixNano()
+
+	for n := idx.root; n != nil; {
+		switch {
+		case key < n.at:
+			n = n.left
+		case key > n.at:
+			n = n.right
+		default:
+			n.ids[id] = struct{}{}
+
+			return
+		}
+	}
+
+	added := &node{at: key, ids: map[uuid.UUID]struct{}{id: {}}, priority: idx.priority()}
+	idx.root = insert(idx.root, added)
+}
+
+func (idx *timeIndex) remove(at time.Time, id uuid.UUID) {
+	key := at.UnixNano()
+
+	for n := idx.root; n != nil; {
+		switch {
+		case key < n.at:
+			n = n.left
+		case key > n.at:
+			n = n.right
+		default:
+			delete(n.ids, id)
+			if len(n.ids) == 0 {
+				idx.root = remove(idx.root, key)
+			}
+
+			return
+		}
+	}
+}
+
+// between returns the IDs from the first time up to the last one, both included, in the time order.
+func (idx *timeIndex) between(from, to time.Time) []uuid.UUID {
+	var ids []uuid.UUID
+	walk(idx.root, from.UnixNano(), to.UnixNano(), func(n *node) {
+		for id := range n.ids {
+			ids = append(ids, id)
+		}
+	})
+
+	return ids
+}
+
+// before returns the IDs up to the time, included when inclusive is set.
+func (idx *timeIndex) before(to time.Time, inclusive bool) []uuid.UUID {
+	last := to.UnixNano()
+	if !inclusive {
+		last--
+	}
+
+	var ids []uuid.UUID
+	walk(idx.root, minTime, last, func(n *node) {
+		for id := range n.ids {
+			ids = append(ids, id)
+		}
+	})
+
+	return ids
+}
+
+// each calls fn for all the IDs in the time order until fn returns false.
+func (idx *timeIndex) each(fn func(id uuid.UUID) bool) {
+	inOrder(idx.root, fn)
+}
+
+const minTime = -1 << 63
+
+func insert(n, added *node) *node {
+	if n == nil {
+		return added
+	}
+
+	if added.at < n.at {
+		n.left = insert(n.left, added)
+		if n.left.priority > n.priority {
+			n = rotateRight(n)
+		}
+	} else {
+		n.right = insert(n.right, added)
+		if n.right.priority > n.priority {
+			n = rotateLeft(n)
+		}
+	}
+
+	return n
+}
+
+func remove(n *node, key int64) *node {
+	switch {
+	case n == nil:
+		return nil
+	case key < n.at:
+		n.left = remove(n.left, key)
+	case key > n.at:
+		n.right = remove(n.right, key)
+	default:
+		return merge(n.left, n.right)
+	}
+
+	return n
+}
+
+func merge(left, right *node) *node {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.priority > right.priority:
+		left.right = merge(left.right, right)
+
+		return left
+	default:
+		right.left = merge(left, right.left)
+
+		return right
+	}
+}
+
+func rotateRight(n *node) *node {
+	l := n.left
+	n.left, l.right = l.right, n
+
+	return l
+}
+
+func rotateLeft(n *node) *node {
+	r := n.right
+	n.right, r.left = r.left, n
+
+	return r
+}
+
+func walk(n *node, from, to int64, fn func(n *node)) {
+	if n == nil {
+		return
+	}
+
+	if n.at >= from {
+		walk(n.left, from, to, fn)
+	}
+
+	if n.at >= from && n.at <= to {
+		fn(n)
+	}
+
+	if n.at <= to {
+		walk(n.right, from, to, fn)
+	}
+}
+
+func inOrder(n *node, fn func(id uuid.UUID) bool) bool {
+	if n == nil {
+		return true
+	}
+
+	if !inOrder(n.left, fn) {
+		return false
+	}
+
+	for id := range n.ids {
+		if !fn(id) {
+			return false
+		}
+	}
+
+	return inOrder(n.right, fn)
+}
@@ -2,7 +2,9 @@ package memorystorage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -13,16 +15,65 @@ import (
 type Elements map[uuid.UUID]storage.Event
 
 var (
-	errEventAlreadyExist = errors.New("event already exist")
-	errEventNotFound     = errors.New("event not found")
+	errEventAlreadyExist = storage.ErrEventExists
+	errEventNotFound     = storage.ErrEventNotFound
+	errStorageClosed     = errors.New("storage is closed")
 )
 
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
 type Storage struct {
-	items Elements
-	mu    sync.RWMutex
+	items     Elements
+	byStart   timeIndex
+	byEnd     timeIndex
+	byNotify  timeIndex             // the events not notified yet
+	longest   time.Duration         // the longest event indexed, it bounds the range search
+	durations map[time.Duration]int // the number of events of each duration, longest shrinks by it on removal
+	outbox    []storage.OutboxMessage
+	leases    map[string]lease
+	mu        sync.RWMutex
+}
+
+func (s *Storage) index(event storage.Event) {
+	duration := event.DatetimeEnd.Sub(event.DatetimeStart)
+	s.durations[duration]++
+	if duration > s.longest {
+		s.longest = duration
+	}
+
+	s.byStart.insert(event.DatetimeStart, event.ID)
+	s.byEnd.insert(event.DatetimeEnd, event.ID)
+	if !event.IsNotified {
+		s.byNotify.insert(event.WhenToNotify, event.ID)
+	}
+}
+
+func (s *Storage) unindex(event storage.Event) {
+	duration := event.DatetimeEnd.Sub(event.DatetimeStart)
+	if s.durations[duration]--; s.durations[duration] == 0 {
+		delete(s.durations, duration)
+
+		if duration == s.longest {
+			s.longest = 0
+			for d := range s.durations {
+				if d > s.longest {
+					s.longest = d
+				}
+			}
+		}
+	}
+
+	s.byStart.remove(event.DatetimeStart, event.ID)
+	s.byEnd.remove(event.DatetimeEnd, event.ID)
+	if !event.IsNotified {
+		s.byNotify.remove(event.WhenToNotify, event.ID)
+	}
 }
 
-func (s *Storage) AddEvent(event storage.Event) error {
+func (s *Storage) AddEvent(_ context.Context, event storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -31,28 +82,37 @@ func (s *Storage) AddEvent(event storage.Event) error {
 	}
 
 	s.items[event.ID] = event
+	s.index(event)
 
 	return nil
 }
 
-func (s *Storage) ChangeEvent(id uuid.UUID, event storage.Event) error {
+// ChangeEvent replaces the event but the notified flag, it is set by SetIsNotified only.
+func (s *Storage) ChangeEvent(_ context.Context, id uuid.UUID, event storage.Event) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, isExist := s.items[id]; !isExist {
+	old, isExist := s.items[id]
+	if !isExist {
 		return errEventNotFound
 	}
 
+	// The edits carry no notified flag, a reminder already sent must not be sent again.
+	event.IsNotified = old.IsNotified
+
+	s.unindex(old)
 	s.items[id] = event
+	s.index(event)
 
 	return nil
 }
 
-func (s *Storage) RemoveEvent(id uuid.UUID) error {
+func (s *Storage) RemoveEvent(_ context.Context, id uuid.UUID) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, isExist := s.items[id]; isExist {
+	if event, isExist := s.items[id]; isExist {
+		s.unindex(event)
 		delete(s.items, id)
 	} else {
 		return errEventNotFound
@@ -61,35 +121,208 @@ func (s *Storage) RemoveEvent(id uuid.UUID) error {
 	return nil
 }
 
-func (s *Storage) ListEventsByRange(p storage.DateRange) (map[uuid.UUID]storage.Event, error) {
+func (s *Storage) GetEvent(_ context.Context, id uuid.UUID) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	event, isExist := s.items[id]
+	if !isExist {
+		return storage.Event{}, errEventNotFound
+	}
+
+	return event, nil
+}
+
+// ListEventsByRange finds the events overlapping the range. Only the events starting at most the longest
+// duration before the range can reach it, so the start index is searched from there.
+func (s *Storage) ListEventsByRange(_ context.Context, p storage.DateRange) (map[uuid.UUID]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[uuid.UUID]storage.Event)
+
+	for _, id := range s.byStart.between(p.Start.Add(-s.longest), p.End) {
+		if event := s.items[id]; p.Includes(event) {
+			result[id] = event
+		}
+	}
+
+	return result, nil
+}
+
+// EachEvent calls fn for the events in the range in the start order and stops at the first error of fn.
+// The storage is locked for reading meanwhile, so fn must not call it.
+func (s *Storage) EachEvent(_ context.Context, p storage.DateRange, fn func(storage.Event) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var err error
+
+	s.byStart.each(func(id uuid.UUID) bool {
+		if event := s.items[id]; p.Includes(event) {
+			err = fn(event)
+		}
+
+		return err == nil
+	})
+
+	return err
+}
+
+// RemoveOldEvents removes the events ended before datetime.
+func (s *Storage) RemoveOldEvents(_ context.Context, datetime time.Time) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	for _, id := range s.byEnd.before(datetime, false) {
+		s.unindex(s.items[id])
+		delete(s.items, id)
+	}
+
+	return nil
+}
+
+// ListEventsForNotification returns the events not notified yet with the notification time up to datetime.
+func (s *Storage) ListEventsForNotification(
+	_ context.Context,
+	datetime time.Time,
+) (map[uuid.UUID]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	result := make(map[uuid.UUID]storage.Event)
+	for _, id := range s.byNotify.before(datetime, true) {
+		result[id] = s.items[id]
+	}
+
+	return result, nil
+}
+
+func (s *Storage) SetIsNotified(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event, isExist := s.items[id]
+	if !isExist {
+		return errEventNotFound
+	}
+
+	s.setIsNotified(event)
+
+	return nil
+}
+
+func (s *Storage) setIsNotified(event storage.Event) {
+	if event.IsNotified {
+		return
+	}
+
+	s.byNotify.remove(event.WhenToNotify, event.ID)
+	event.IsNotified = true
+	s.items[event.ID] = event
+}
 
-	for _, item := range s.items {
-		lessOrEqualStart := item.DatetimeStart.After(p.Start) || item.DatetimeStart.Equal(p.Start)
-		moreOrEqualStart := item.DatetimeStart.Before(p.End) || item.DatetimeStart.Equal(p.End)
+// ClaimNotifications moves the reminders due until datetime to the outbox and marks their events notified
+// under one lock, the same way the SQL storage does in a transaction.
+func (s *Storage) ClaimNotifications(_ context.Context, datetime, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := 0
+
+	for _, id := range s.byNotify.before(datetime, true) {
+		event := s.items[id]
+		notification := storage.NewNotification(event, now)
 
-		lessOrEqualEnd := item.DatetimeEnd.Before(p.Start) || item.DatetimeEnd.Equal(p.Start)
-		moreOrEqualEnd := item.DatetimeEnd.After(p.End) || item.DatetimeEnd.Equal(p.End)
-		if (lessOrEqualStart && moreOrEqualStart) || (lessOrEqualEnd && moreOrEqualEnd) {
-			result[item.ID] = item
+		payload, err := json.Marshal(notification)
+		if err != nil {
+			return claimed, fmt.Errorf("marshal notification: %w", err)
 		}
+
+		s.outbox = append(s.outbox, storage.OutboxMessage{ID: notification.MessageID, Payload: payload})
+
+		s.setIsNotified(event)
+		claimed++
 	}
 
-	return result, nil
+	return claimed, nil
 }
 
-func (s *Storage) RemoveOldEvents(datetime time.Time) error {
+// ListOutbox returns the oldest unpublished notifications.
+func (s *Storage) ListOutbox(_ context.Context, limit int) ([]storage.OutboxMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if limit > len(s.outbox) {
+		limit = len(s.outbox)
+	}
+
+	messages := make([]storage.OutboxMessage, limit)
+	copy(messages, s.outbox)
+
+	return messages, nil
+}
+
+// AddOutbox puts a message claimed elsewhere to the outbox, e.g. when a log is replayed.
+func (s *Storage) AddOutbox(message storage.OutboxMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.outbox = append(s.outbox, message)
+}
+
+// Events returns all the events in no particular order.
+func (s *Storage) Events() []storage.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0, len(s.items))
+	for _, event := range s.items {
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func (s *Storage) DeleteOutbox(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, message := range s.outbox {
+		if message.ID == id {
+			s.outbox = append(s.outbox[:i], s.outbox[i+1:]...)
+
+			return nil
+		}
+	}
+
 	return nil
 }
 
-func (s *Storage) ListEventsForNotification(datetime time.Time) (map[uuid.UUID]storage.Event, error) {
-	return nil, nil
+// AcquireLease takes the lease when it is free or expired and renews it for its holder.
+func (s *Storage) AcquireLease(_ context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if current, ok := s.leases[name]; ok && current.holder != holder && now.Before(current.expiresAt) {
+		return false, nil
+	}
+
+	s.leases[name] = lease{holder: holder, expiresAt: now.Add(ttl)}
+
+	return true, nil
 }
 
-func (s *Storage) SetIsNotified(id uuid.UUID) error {
+func (s *Storage) ReleaseLease(_ context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.leases[name]; ok && current.holder == holder {
+		delete(s.leases, name)
+	}
+
 	return nil
 }
 
@@ -97,17 +330,33 @@ func (s *Storage) Connect(_ context.Context) error {
 	return nil
 }
 
+func (s *Storage) Ping(_ context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.items == nil {
+		return errStorageClosed
+	}
+
+	return nil
+}
+
 func (s *Storage) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.items = nil
+	s.byStart, s.byEnd, s.byNotify = timeIndex{}, timeIndex{}, timeIndex{}
+	s.durations, s.longest = make(map[time.Duration]int), 0
+	s.outbox = nil
 
 	return nil
 }
 
 func New() *Storage {
 	return &Storage{
-		items: make(map[uuid.UUID]storage.Event),
+		items:     make(map[uuid.UUID]storage.Event),
+		durations: make(map[time.Duration]int),
+		leases:    make(map[string]lease),
 	}
 }
@@ -1,11 +1,13 @@
 package memorystorage
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
 
 	storage2 "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/storagetest"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
@@ -48,6 +50,7 @@ func getDateRange(startDate time.Time) (*storage2.DateRange, error) {
 }
 
 func TestStorage(t *testing.T) {
+	ctx := context.Background()
 	firstID := uuid.New()
 	secondID := uuid.New()
 	userID := uuid.New()
@@ -56,31 +59,31 @@ func TestStorage(t *testing.T) {
 		storage := New()
 
 		events := getEvents(firstID, secondID, userID)
-		err := storage.AddEvent(events[firstID])
+		err := storage.AddEvent(ctx, events[firstID])
 		require.Nil(t, err)
 
-		err = storage.AddEvent(events[secondID])
+		err = storage.AddEvent(ctx, events[secondID])
 		require.Nil(t, err)
 
 		dateRange, err := getDateRange(events[secondID].DatetimeStart)
 		require.Nil(t, err)
 
-		result, err := storage.ListEventsByRange(*dateRange)
+		result, err := storage.ListEventsByRange(ctx, *dateRange)
 		require.Nil(t, err)
 		require.Equal(t, events, result)
 
-		err = storage.RemoveEvent(firstID)
+		err = storage.RemoveEvent(ctx, firstID)
 		require.Nil(t, err)
 
-		result, err = storage.ListEventsByRange(*dateRange)
+		result, err = storage.ListEventsByRange(ctx, *dateRange)
 		require.Nil(t, err)
 		delete(events, firstID)
 		require.Equal(t, events, result)
 
-		err = storage.RemoveEvent(secondID)
+		err = storage.RemoveEvent(ctx, secondID)
 		require.Nil(t, err)
 
-		result, err = storage.ListEventsByRange(*dateRange)
+		result, err = storage.ListEventsByRange(ctx, *dateRange)
 		require.Nil(t, err)
 		delete(events, secondID)
 		require.Equal(t, events, result)
@@ -93,10 +96,10 @@ func TestStorage(t *testing.T) {
 		storage := New()
 
 		events := getEvents(firstID, secondID, userID)
-		err := storage.AddEvent(events[firstID])
+		err := storage.AddEvent(ctx, events[firstID])
 		require.Nil(t, err)
 
-		err = storage.AddEvent(events[firstID])
+		err = storage.AddEvent(ctx, events[firstID])
 		require.ErrorIs(t, err, errEventAlreadyExist)
 
 		err = storage.Close()
@@ -106,11 +109,11 @@ func TestStorage(t *testing.T) {
 	t.Run("notfound tests", func(t *testing.T) {
 		storage := New()
 
-		err := storage.RemoveEvent(firstID)
+		err := storage.RemoveEvent(ctx, firstID)
 		require.ErrorIs(t, err, errEventNotFound)
 
 		events := getEvents(firstID, secondID, userID)
-		err = storage.ChangeEvent(firstID, events[firstID])
+		err = storage.ChangeEvent(ctx, firstID, events[firstID])
 		require.ErrorIs(t, err, errEventNotFound)
 
 		err = storage.Close()
@@ -121,19 +124,19 @@ func TestStorage(t *testing.T) {
 		storage := New()
 
 		events := getEvents(firstID, secondID, userID)
-		err := storage.AddEvent(events[firstID])
+		err := storage.AddEvent(ctx, events[firstID])
 		require.Nil(t, err)
 
 		event := events[firstID]
 		event.Title = "new new text"
 
-		err = storage.ChangeEvent(firstID, event)
+		err = storage.ChangeEvent(ctx, firstID, event)
 		require.Nil(t, err)
 
 		dateRange, err := getDateRange(events[secondID].DatetimeStart)
 		require.Nil(t, err)
 
-		result, err := storage.ListEventsByRange(*dateRange)
+		result, err := storage.ListEventsByRange(ctx, *dateRange)
 		require.Nil(t, err)
 		require.Equal(t, map[uuid.UUID]storage2.Event{firstID: event}, result)
 
@@ -142,7 +145,94 @@ func TestStorage(t *testing.T) {
 	})
 }
 
+func TestSchedulerOperations(t *testing.T) {
+	ctx := context.Background()
+	storage := New()
+
+	base := time.Date(2022, time.June, 1, 10, 0, 0, 0, time.UTC)
+	events := make([]storage2.Event, 5)
+	for i := range events {
+		events[i] = storage2.Event{
+			ID:            uuid.New(),
+			DatetimeStart: base.Add(time.Duration(i) * time.Hour),
+			DatetimeEnd:   base.Add(time.Duration(i)*time.Hour + 30*time.Minute),
+			WhenToNotify:  base.Add(time.Duration(i)*time.Hour - 10*time.Minute),
+		}
+		require.Nil(t, storage.AddEvent(ctx, events[i]))
+	}
+
+	due, err := storage.ListEventsForNotification(ctx, base.Add(time.Hour))
+	require.Nil(t, err)
+	require.Len(t, due, 2)
+	require.Contains(t, due, events[0].ID)
+	require.Contains(t, due, events[1].ID)
+
+	require.Nil(t, storage.SetIsNotified(ctx, events[0].ID))
+	require.ErrorIs(t, storage.SetIsNotified(ctx, uuid.New()), errEventNotFound)
+
+	due, err = storage.ListEventsForNotification(ctx, base.Add(time.Hour))
+	require.Nil(t, err)
+	require.Len(t, due, 1)
+
+	event, err := storage.GetEvent(ctx, events[0].ID)
+	require.Nil(t, err)
+	require.True(t, event.IsNotified)
+
+	// A changed notification time moves the event in the index.
+	changed := events[4]
+	changed.WhenToNotify = base
+	require.Nil(t, storage.ChangeEvent(ctx, changed.ID, changed))
+
+	due, err = storage.ListEventsForNotification(ctx, base.Add(time.Hour))
+	require.Nil(t, err)
+	require.Len(t, due, 2)
+	require.Contains(t, due, changed.ID)
+
+	// The first two events ended before the third one started.
+	require.Nil(t, storage.RemoveOldEvents(ctx, events[2].DatetimeStart))
+
+	_, err = storage.GetEvent(ctx, events[1].ID)
+	require.ErrorIs(t, err, errEventNotFound)
+
+	result, err := storage.ListEventsByRange(ctx, storage2.DateRange{Start: base, End: base.Add(24 * time.Hour)})
+	require.Nil(t, err)
+	require.Len(t, result, 3)
+}
+
+func TestTimeIndex(t *testing.T) {
+	idx := timeIndex{}
+	base := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	times := make(map[uuid.UUID]time.Time)
+	for i := 0; i < 1000; i++ {
+		id := uuid.New()
+		times[id] = base.Add(time.Duration(i%97) * time.Minute)
+		idx.insert(times[id], id)
+	}
+
+	for id, at := range times {
+		if at.Minute()%2 == 0 {
+			idx.remove(at, id)
+			delete(times, id)
+		}
+	}
+
+	from, to := base.Add(10*time.Minute), base.Add(50*time.Minute)
+
+	var expected []uuid.UUID
+	for id, at := range times {
+		if !at.Before(from) && !at.After(to) {
+			expected = append(expected, id)
+		}
+	}
+
+	require.ElementsMatch(t, expected, idx.between(from, to))
+	require.Len(t, idx.before(base.Add(96*time.Minute), true), len(times))
+	require.Empty(t, idx.before(base.Add(time.Minute), false))
+}
+
 func TestCacheMultithreading(t *testing.T) {
+	ctx := context.Background()
 	storage := New()
 
 	wg := &sync.WaitGroup{}
@@ -165,7 +255,7 @@ func TestCacheMultithreading(t *testing.T) {
 				UserID:        userID,
 				WhenToNotify:  timeWhen,
 			}
-			err := storage.AddEvent(event)
+			err := storage.AddEvent(ctx, event)
 			require.Nil(t, err)
 		}
 	}()
@@ -182,10 +272,40 @@ func TestCacheMultithreading(t *testing.T) {
 				UserID:        userID,
 				WhenToNotify:  timeWhen,
 			}
-			err := storage.AddEvent(event)
+			err := storage.AddEvent(ctx, event)
 			require.Nil(t, err)
 		}
 	}()
 
 	wg.Wait()
 }
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		return New()
+	})
+}
+
+func TestLongestShrinks(t *testing.T) {
+	ctx := context.Background()
+	storage := New()
+	base := time.Date(2022, time.January, 10, 10, 0, 0, 0, time.UTC)
+
+	short := storagetest.NewEvent(base, base.Add(time.Hour))
+	long := storagetest.NewEvent(base, base.Add(30*24*time.Hour))
+	require.Nil(t, storage.AddEvent(ctx, short))
+	require.Nil(t, storage.AddEvent(ctx, long))
+	require.Equal(t, 30*24*time.Hour, storage.longest)
+
+	require.Nil(t, storage.RemoveEvent(ctx, long.ID))
+	require.Equal(t, time.Hour, storage.longest)
+
+	short.DatetimeEnd = base.Add(2 * time.Hour)
+	require.Nil(t, storage.ChangeEvent(ctx, short.ID, short))
+	require.Equal(t, 2*time.Hour, storage.longest)
+
+	require.Nil(t, storage.RemoveOldEvents(ctx, base.Add(3*time.Hour)))
+	require.Zero(t, storage.longest)
+}
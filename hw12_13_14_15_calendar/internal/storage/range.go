@@ -1,8 +1,28 @@
 package storage
 
-import "time"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// DateRange selects the events overlapping the half-open interval [Start, End): the events starting
+// before End and ending after Start. An event without duration is selected when Start <= its start < End.
 type DateRange struct {
-	Start time.Time
-	End   time.Time
+	Start  time.Time
+	End    time.Time
+	UserID uuid.UUID // the owner of the events, any when zero
+}
+
+// Includes reports whether the event is selected by the range.
+func (r DateRange) Includes(event Event) bool {
+	if r.UserID != uuid.Nil && event.UserID != r.UserID {
+		return false
+	}
+
+	if !event.DatetimeStart.Before(r.End) {
+		return false
+	}
+
+	return event.DatetimeEnd.After(r.Start) || !event.DatetimeStart.Before(r.Start)
 }
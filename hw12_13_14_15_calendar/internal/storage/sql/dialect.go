@@ -0,0 +1,37 @@
+package sqlstorage
+
+// Dialect describes what differs between the databases behind the SQL storage.
+type Dialect struct {
+	// Driver is the database/sql driver name.
+	Driver string
+	// System is the database name reported in the metrics and traces.
+	System string
+	// Migrations is the directory with the dialect migrations.
+	Migrations string
+	// lockRows locks the selected rows for the transaction, skipping the rows locked by others.
+	lockRows string
+}
+
+var (
+	Postgres = Dialect{
+		Driver:     "pgx",
+		System:     "postgresql",
+		Migrations: "migrations/postgres",
+		lockRows:   "for update skip locked",
+	}
+	// SQLite serializes the writing transactions, so there is nothing to lock.
+	SQLite = Dialect{
+		Driver:     "sqlite3",
+		System:     "sqlite",
+		Migrations: "migrations/sqlite",
+	}
+)
+
+// DialectFor returns the dialect of the driver, falling back to Postgres.
+func DialectFor(driver string) Dialect {
+	if driver == SQLite.Driver {
+		return SQLite
+	}
+
+	return Postgres
+}
@@ -0,0 +1,58 @@
+//go:build integration
+// +build integration
+
+package sqlstorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/storagetest"
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresConformance runs the suite against the database of CALENDAR_TEST_POSTGRES_DSN.
+// The tables of the database are recreated.
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("CALENDAR_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CALENDAR_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sqlx.Open(Postgres.Driver, dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`drop table if exists events, outbox, leases, schema_migrations`)
+	require.NoError(t, err)
+
+	ups, err := filepath.Glob(filepath.Join("..", "..", "..", Postgres.Migrations, "*.up.sql"))
+	require.NoError(t, err)
+	sort.Strings(ups)
+
+	for _, up := range ups {
+		migration, err := os.ReadFile(up)
+		require.NoError(t, err)
+		_, err = db.Exec(string(migration))
+		require.NoError(t, err, up)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		_, err := db.Exec(`truncate events, outbox, leases`)
+		require.NoError(t, err)
+
+		s := New(config.NewConfig(), dsn)
+		require.NoError(t, s.Connect(context.Background()))
+		t.Cleanup(func() { s.Close() })
+
+		return s
+	})
+}
@@ -0,0 +1,155 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jmoiron/sqlx"
+)
+
+// replica is a read-only copy of the database. It is skipped while its last health check failed.
+type replica struct {
+	db      *sqlx.DB
+	healthy int32
+}
+
+func (r *replica) isHealthy() bool {
+	return atomic.LoadInt32(&r.healthy) == 1
+}
+
+func (r *replica) setHealthy(healthy bool) {
+	var value int32
+	if healthy {
+		value = 1
+	}
+
+	atomic.StoreInt32(&r.healthy, value)
+}
+
+// replicas spread the read-only queries over the healthy replicas in turn.
+type replicas struct {
+	list []*replica
+	next uint32
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// openReplicas opens the pools of the replicas, a replica down at the start is only marked unhealthy.
+func openReplicas(ctx context.Context, dialect Dialect, pool config.SQLDatabase) (*replicas, error) {
+	rs := &replicas{done: make(chan struct{})}
+
+	for _, dsn := range pool.Replicas {
+		db, err := open(dialect, dsn, pool)
+		if err != nil {
+			_ = rs.close()
+
+			return nil, fmt.Errorf("open replica: %w", err)
+		}
+
+		r := &replica{db: db}
+		r.setHealthy(db.PingContext(ctx) == nil)
+		rs.list = append(rs.list, r)
+	}
+
+	return rs, nil
+}
+
+// pick returns the next healthy replica, nil when there is none.
+func (rs *replicas) pick() *replica {
+	for range rs.list {
+		r := rs.list[atomic.AddUint32(&rs.next, 1)%uint32(len(rs.list))]
+		if r.isHealthy() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// watch pings the replicas every interval until close, so a replica back in service gets the queries again.
+func (rs *replicas) watch(interval time.Duration) {
+	rs.wg.Add(1)
+
+	go func() {
+		defer rs.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-rs.done:
+				return
+			case <-ticker.C:
+				rs.check(interval)
+			}
+		}
+	}()
+}
+
+func (rs *replicas) check(timeout time.Duration) {
+	for _, r := range rs.list {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		r.setHealthy(r.db.PingContext(ctx) == nil)
+		cancel()
+	}
+}
+
+func (rs *replicas) close() error {
+	close(rs.done)
+	rs.wg.Wait()
+
+	var err error
+	for _, r := range rs.list {
+		if closeErr := r.db.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// unavailable reports whether err is a failure of the node rather than of the query, a query failed
+// on one node fails the same on any other.
+func (d Dialect) unavailable(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || d.transient(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) &&
+		(pgerrcode.IsConnectionException(pgErr.Code) || pgerrcode.IsOperatorIntervention(pgErr.Code))
+}
+
+// read runs the read-only op on a healthy replica and falls back to the primary when there is none
+// or the replica is unavailable. The unavailable replica is skipped until its next successful health check,
+// the other errors are returned as they are.
+func (s *Storage) read(ctx context.Context, op func(db *sqlx.DB) error) error {
+	if s.replicas != nil {
+		if r := s.replicas.pick(); r != nil {
+			err := op(r.db)
+			if err == nil || ctx.Err() != nil || !s.dialect.unavailable(err) {
+				return err
+			}
+
+			r.setHealthy(false)
+		}
+	}
+
+	return s.primary(ctx, op)
+}
@@ -0,0 +1,79 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicas(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLite(t, migrated(t))
+	require.Len(t, s.replicas.list, 1)
+	replica := s.replicas.list[0]
+	require.True(t, replica.isHealthy())
+
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	event := storage.Event{
+		ID:            uuid.New(),
+		Title:         "Test title",
+		DatetimeStart: start,
+		DatetimeEnd:   start.Add(15 * time.Minute),
+		UserID:        uuid.New(),
+		WhenToNotify:  start,
+	}
+	day := storage.DateRange{Start: start.Add(-time.Hour), End: start.Add(time.Hour)}
+
+	// The test replica is not replicated, so the event written to the primary is missing there.
+	require.NoError(t, s.AddEvent(ctx, event))
+
+	_, err := s.GetEvent(ctx, event.ID)
+	require.NoError(t, err, "reading a single event goes to the primary")
+
+	events, err := s.ListEventsByRange(ctx, day)
+	require.NoError(t, err)
+	require.Empty(t, events, "listing goes to the replica")
+
+	// A failed query fails on any node, so it is returned and the replica stays in service.
+	errQuery := errors.New("no such column: titel")
+	require.ErrorIs(t, s.read(ctx, func(*sqlx.DB) error { return errQuery }), errQuery)
+	require.True(t, replica.isHealthy())
+
+	calls := 0
+	require.NoError(t, s.read(ctx, func(*sqlx.DB) error {
+		if calls++; calls == 1 {
+			return driver.ErrBadConn
+		}
+
+		return nil
+	}))
+	require.Equal(t, 2, calls, "the lost connection is retried on the primary")
+	require.False(t, replica.isHealthy())
+
+	events, err = s.ListEventsByRange(ctx, day)
+	require.NoError(t, err)
+	require.Contains(t, events, event.ID, "listing falls back to the primary")
+
+	require.NoError(t, replica.db.Close())
+	s.replicas.check(time.Second)
+	require.False(t, replica.isHealthy(), "the closed replica fails the health check")
+}
+
+func TestUnavailable(t *testing.T) {
+	require.True(t, Postgres.unavailable(&pgconn.PgError{Code: pgerrcode.AdminShutdown}))
+	require.True(t, Postgres.unavailable(&pgconn.PgError{Code: pgerrcode.ConnectionFailure}))
+	require.True(t, Postgres.unavailable(fmt.Errorf("query: %w", &net.OpError{Op: "read", Err: errors.New("reset")})))
+	require.False(t, Postgres.unavailable(&pgconn.PgError{Code: pgerrcode.UndefinedColumn}))
+	require.False(t, SQLite.unavailable(errors.New("no such table: events")))
+}
@@ -0,0 +1,75 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+)
+
+const maxBackoff = 5 * time.Second
+
+// transient reports whether the failed statement surely was not applied and may succeed when repeated.
+func (d Dialect) transient(err error) bool {
+	if d == SQLite {
+		return sqliteTransient(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.SerializationFailure,
+			pgerrcode.DeadlockDetected,
+			pgerrcode.LockNotAvailable,
+			pgerrcode.TooManyConnections,
+			pgerrcode.CannotConnectNow:
+			return true
+		}
+
+		return false
+	}
+
+	return pgconn.SafeToRetry(err)
+}
+
+// duplicate reports whether the statement failed on a taken unique key.
+func (d Dialect) duplicate(err error) bool {
+	if d == SQLite {
+		return sqliteDuplicate(err)
+	}
+
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation
+}
+
+// retry runs op until it succeeds, fails with a permanent error, runs out of the attempts or ctx is done.
+// The delay between the attempts starts from backoff and doubles up to maxBackoff.
+func retry(ctx context.Context, retries int, backoff time.Duration, transient func(error) bool, op func() error) error {
+	err := op()
+
+	for attempt := 0; attempt < retries && err != nil && transient(err); attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+
+		err = op()
+	}
+
+	return err
+}
+
+// withRetry repeats op failed with a transient error.
+func (s *Storage) withRetry(ctx context.Context, op func() error) error {
+	return retry(ctx, s.config.DB.SQL.QueryRetries, s.config.DB.SQL.RetryBackoff, s.dialect.transient, op)
+}
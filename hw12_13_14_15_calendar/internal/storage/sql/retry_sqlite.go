@@ -0,0 +1,26 @@
+//go:build cgo
+// +build cgo
+
+package sqlstorage
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteTransient reports whether SQLite failed on a lock held by another connection.
+func sqliteTransient(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// sqliteDuplicate reports whether SQLite failed on a taken primary or unique key.
+func sqliteDuplicate(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique)
+}
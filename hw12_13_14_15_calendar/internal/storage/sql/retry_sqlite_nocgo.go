@@ -0,0 +1,14 @@
+//go:build !cgo
+// +build !cgo
+
+package sqlstorage
+
+// sqliteTransient is never true without cgo, the SQLite driver cannot open a database then.
+func sqliteTransient(error) bool {
+	return false
+}
+
+// sqliteDuplicate is never true without cgo either.
+func sqliteDuplicate(error) bool {
+	return false
+}
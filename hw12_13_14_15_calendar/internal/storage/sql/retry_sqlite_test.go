@@ -0,0 +1,26 @@
+//go:build cgo
+// +build cgo
+
+package sqlstorage
+
+import (
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteTransient(t *testing.T) {
+	require.True(t, SQLite.transient(sqlite3.Error{Code: sqlite3.ErrBusy}))
+	require.True(t, SQLite.transient(sqlite3.Error{Code: sqlite3.ErrLocked}))
+	require.False(t, SQLite.transient(sqlite3.Error{Code: sqlite3.ErrConstraint}))
+	require.False(t, SQLite.transient(&pgconn.PgError{Code: pgerrcode.DeadlockDetected}))
+}
+
+func TestSQLiteDuplicate(t *testing.T) {
+	require.True(t, SQLite.duplicate(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintPrimaryKey}))
+	require.False(t, SQLite.duplicate(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintNotNull}))
+	require.True(t, Postgres.duplicate(&pgconn.PgError{Code: pgerrcode.UniqueViolation}))
+}
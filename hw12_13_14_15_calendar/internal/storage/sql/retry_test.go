@@ -0,0 +1,71 @@
+package sqlstorage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransient(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: pgerrcode.DeadlockDetected}
+	require.True(t, Postgres.transient(deadlock))
+	require.False(t, Postgres.transient(&pgconn.PgError{Code: pgerrcode.UniqueViolation}))
+	require.False(t, Postgres.transient(errors.New("syntax error")))
+}
+
+func TestRetry(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	transient := func(err error) bool { return errors.Is(err, errTransient) }
+
+	t.Run("until success", func(t *testing.T) {
+		calls := 0
+		err := retry(context.Background(), 3, time.Millisecond, transient, func() error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("attempts are limited", func(t *testing.T) {
+		calls := 0
+		err := retry(context.Background(), 2, time.Millisecond, transient, func() error {
+			calls++
+			return errTransient
+		})
+		require.ErrorIs(t, err, errTransient)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		calls := 0
+		err := retry(context.Background(), 5, time.Millisecond, transient, func() error {
+			calls++
+			return errPermanent
+		})
+		require.ErrorIs(t, err, errPermanent)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		calls := 0
+		err := retry(ctx, 5, time.Hour, transient, func() error {
+			calls++
+			return errTransient
+		})
+		require.ErrorIs(t, err, errTransient)
+		require.Equal(t, 1, calls)
+	})
+}
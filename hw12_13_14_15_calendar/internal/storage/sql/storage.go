@@ -2,6 +2,9 @@ package sqlstorage
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,194 +14,467 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-const datetimeFormat = "2006-Jan-02 15:04:05"
+var (
+	errEventNotFound = storage.ErrEventNotFound
+	errNotConnected  = errors.New("storage is not connected")
+)
 
 type Storage struct {
-	dsn    string
-	db     *sqlx.DB
-	config config.Config
-	ctx    context.Context
+	dsn      string
+	dialect  Dialect
+	db       *sqlx.DB
+	replicas *replicas
+	config   config.Config
 }
 
-func (s *Storage) AddEvent(e storage.Event) error {
+func (s *Storage) AddEvent(ctx context.Context, e storage.Event) error {
 	query := `insert
-				into events(id, title, datetime_start, datetime_end, description, user_id, when_to_notify)
-				values($1, $2, $3, $4, $5, $6, $7)`
+				into events(id, title, datetime_start, datetime_end, description, user_id, when_to_notify, channel)
+				values(?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := s.db.ExecContext(
-		s.ctx,
+	err := s.exec(
+		ctx,
 		query,
 		e.ID,
 		e.Title,
-		e.DatetimeStart,
-		e.DatetimeEnd,
+		e.DatetimeStart.UTC(),
+		e.DatetimeEnd.UTC(),
 		e.Description,
 		e.UserID,
-		e.WhenToNotify)
-	if err != nil {
-		return err
+		e.WhenToNotify.UTC(),
+		e.Channel)
+	if s.dialect.duplicate(err) {
+		return fmt.Errorf("add event %s: %w", e.ID, storage.ErrEventExists)
 	}
 
-	return nil
+	return err
 }
 
-func (s *Storage) ChangeEvent(id uuid.UUID, event storage.Event) error {
+// ChangeEvent replaces the event but the notified flag, it is set by SetIsNotified only.
+func (s *Storage) ChangeEvent(ctx context.Context, id uuid.UUID, event storage.Event) error {
 	query := `update
 				events
 			  set
-				title = $1,
-				datetime_start = $2,
-				datetime_end = $3,
-				description = $4,
-				user_id = $5,
-				when_to_notify = $6
+				title = ?,
+				datetime_start = ?,
+				datetime_end = ?,
+				description = ?,
+				user_id = ?,
+				when_to_notify = ?,
+				channel = ?
 			  where
-			    id = $7`
+			    id = ?`
 
-	_, err := s.db.ExecContext(
-		s.ctx,
+	return s.execEvent(
+		ctx,
 		query,
 		event.Title,
-		event.DatetimeStart.Format(time.RFC3339),
-		event.DatetimeEnd.Format(time.RFC3339),
+		event.DatetimeStart.UTC(),
+		event.DatetimeEnd.UTC(),
 		event.Description,
 		event.UserID,
-		event.WhenToNotify,
+		event.WhenToNotify.UTC(),
+		event.Channel,
 		id,
 	)
-
-	return err
 }
 
-func (s *Storage) RemoveEvent(id uuid.UUID) error {
-	query := "delete from events where id = $1"
+func (s *Storage) RemoveEvent(ctx context.Context, id uuid.UUID) error {
+	query := "delete from events where id = ?"
 
-	_, err := s.db.ExecContext(s.ctx, query, id)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return s.execEvent(ctx, query, id)
 }
 
-func (s *Storage) ListEventsByRange(p storage.DateRange) (map[uuid.UUID]storage.Event, error) {
-	query := `select 
+func (s *Storage) GetEvent(ctx context.Context, id uuid.UUID) (storage.Event, error) {
+	query := `select
     			id,
     			title,
-    			datetime_start as datetimeStart,
-    			datetime_end as datetimeEnd,
+    			datetime_start as datetimestart,
+    			datetime_end as datetimeend,
     			description,
-    			user_id as userId,
-    			when_to_notify as whenToNotify,
-    			is_notified as isNotified
+    			user_id as userid,
+    			when_to_notify as whentonotify,
+    			is_notified as isnotified,
+    			channel
 			  from
 			    events
 			  where
-			    (datetime_start >= $1 and datetime_start <= $2) or (datetime_end >= $1 and datetime_end <= $2)`
+			    id = ?`
+
+	var event storage.Event
+
+	err := s.withRetry(ctx, func() error {
+		return s.db.QueryRowxContext(ctx, s.db.Rebind(query), id).StructScan(&event)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, errEventNotFound
+	}
+
+	return event, err
+}
+
+// ListEventsByRange finds the events overlapping the range, see storage.DateRange.
+func (s *Storage) ListEventsByRange(ctx context.Context, p storage.DateRange) (map[uuid.UUID]storage.Event, error) {
+	query, args := rangeQuery(p)
 
-	rows, err := s.db.QueryxContext(s.ctx, query, p.Start.Format(time.RFC3339), p.End.Format(time.RFC3339))
+	return s.selectEvents(ctx, s.read, query, args...)
+}
+
+// EachEvent streams the events in the range in the start order and stops at the first error of fn.
+func (s *Storage) EachEvent(ctx context.Context, p storage.DateRange, fn func(storage.Event) error) error {
+	query, args := rangeQuery(p)
+
+	rows, err := s.db.QueryxContext(ctx, s.db.Rebind(query+` order by datetime_start`), args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	events := make(map[uuid.UUID]storage.Event)
-
 	for rows.Next() {
 		var event storage.Event
-		err := rows.StructScan(&event)
-		if err != nil {
-			return nil, err
+		if err := rows.StructScan(&event); err != nil {
+			return err
 		}
-		events[event.ID] = event
-	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+		if err := fn(event); err != nil {
+			return err
+		}
 	}
 
-	return events, nil
+	return rows.Err()
 }
 
-func (s *Storage) RemoveOldEvents(datetime time.Time) error {
-	query := `delete from events where datetime_end < $1`
+func rangeQuery(p storage.DateRange) (string, []interface{}) {
+	query := `select 
+    			id,
+    			title,
+    			datetime_start as datetimestart,
+    			datetime_end as datetimeend,
+    			description,
+    			user_id as userid,
+    			when_to_notify as whentonotify,
+    			is_notified as isnotified,
+    			channel
+			  from
+			    events
+			  where
+			    datetime_start < ?
+			    and (datetime_end > ? or datetime_start >= ?)`
 
-	_, err := s.db.ExecContext(s.ctx, query, datetime.Format(datetimeFormat))
-	if err != nil {
-		return err
+	start, end := p.Start.UTC(), p.End.UTC()
+	args := []interface{}{end, start, start}
+
+	if p.UserID != uuid.Nil {
+		query += ` and user_id = ?`
+		args = append(args, p.UserID)
 	}
 
-	return nil
+	return query, args
 }
 
-func (s *Storage) SetIsNotified(id uuid.UUID) error {
-	query := `update events set is_notified = true where id = $1`
+func (s *Storage) RemoveOldEvents(ctx context.Context, datetime time.Time) error {
+	query := `delete from events where datetime_end < ?`
+
+	return s.exec(ctx, query, datetime.UTC())
+}
 
-	_, err := s.db.ExecContext(s.ctx, query, id)
+func (s *Storage) SetIsNotified(ctx context.Context, id uuid.UUID) error {
+	query := `update events set is_notified = true where id = ?`
 
-	return err
+	return s.execEvent(ctx, query, id)
 }
 
-func (s *Storage) ListEventsForNotification(datetime time.Time) (map[uuid.UUID]storage.Event, error) {
+func (s *Storage) ListEventsForNotification(
+	ctx context.Context,
+	datetime time.Time,
+) (map[uuid.UUID]storage.Event, error) {
 	query := `select 
     			id,
     			title,
-    			datetime_start as datetimeStart,
-    			datetime_end as datetimeEnd,
+    			datetime_start as datetimestart,
+    			datetime_end as datetimeend,
     			description,
-    			user_id as userId,
-    			is_notified as isNotified,
-    			when_to_notify as whenToNotify
+    			user_id as userid,
+    			is_notified as isnotified,
+    			when_to_notify as whentonotify,
+    			channel
 			  from
 			    events
 			  where
-			    when_to_notify <= $1
+			    when_to_notify <= ?
 				and is_notified = false`
 
-	rows, err := s.db.QueryxContext(s.ctx, query, datetime.Format(datetimeFormat))
+	return s.selectEvents(ctx, s.primary, query, datetime.UTC())
+}
+
+// ClaimNotifications moves the reminders due until datetime to the outbox and marks their events notified
+// in one transaction, so a reminder is neither lost nor claimed twice.
+func (s *Storage) ClaimNotifications(ctx context.Context, datetime, now time.Time) (int, error) {
+	var claimed int
+
+	err := s.withRetry(ctx, func() (err error) {
+		claimed, err = s.claim(ctx, datetime, now)
+
+		return err
+	})
+
+	return claimed, err
+}
+
+func (s *Storage) claim(ctx context.Context, datetime, now time.Time) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("begin transaction: %w", err)
 	}
-	defer rows.Close()
+	// Rollback is a no-op after the commit.
+	defer func() { _ = tx.Rollback() }()
 
-	events := make(map[uuid.UUID]storage.Event)
+	query := `select 
+    			id,
+    			title,
+    			datetime_start as datetimestart,
+    			datetime_end as datetimeend,
+    			description,
+    			user_id as userid,
+    			is_notified as isnotified,
+    			when_to_notify as whentonotify,
+    			channel
+			  from
+			    events
+			  where
+			    when_to_notify <= ?
+				and is_notified = false
+			  ` + s.dialect.lockRows
 
-	for rows.Next() {
-		var event storage.Event
-		err := rows.StructScan(&event)
+	var events []storage.Event
+	if err := tx.SelectContext(ctx, &events, tx.Rebind(query), datetime.UTC()); err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		notification := storage.NewNotification(event, now)
+
+		payload, err := json.Marshal(notification)
 		if err != nil {
-			return nil, err
+			return 0, fmt.Errorf("marshal notification: %w", err)
 		}
-		events[event.ID] = event
+
+		_, err = tx.ExecContext(
+			ctx,
+			tx.Rebind(`insert into outbox(id, event_id, payload) values(?, ?, ?)`),
+			notification.MessageID,
+			event.ID,
+			payload)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, tx.Rebind(`update events set is_notified = true where id = ?`), event.ID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
+	return len(events), nil
+}
+
+// ListOutbox returns the oldest unpublished notifications.
+func (s *Storage) ListOutbox(ctx context.Context, limit int) ([]storage.OutboxMessage, error) {
+	query := `select id, payload from outbox order by created_at limit ?`
+
+	var messages []storage.OutboxMessage
+
+	err := s.withRetry(ctx, func() error {
+		messages = nil
+
+		return s.db.SelectContext(ctx, &messages, s.db.Rebind(query), limit)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return events, nil
+	return messages, nil
+}
+
+func (s *Storage) DeleteOutbox(ctx context.Context, id uuid.UUID) error {
+	return s.exec(ctx, `delete from outbox where id = ?`, id)
 }
 
+// AcquireLease takes the lease when it is free or expired and renews it for its holder.
+// The replicas count the expiry by their own clocks, so the lease must be much longer than their drift.
+func (s *Storage) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	query := `insert into leases(name, holder, expires_at)
+				values(?, ?, ?)
+			  on conflict (name) do update
+				set holder = excluded.holder, expires_at = excluded.expires_at
+				where leases.holder = excluded.holder or leases.expires_at < ?
+			  returning holder`
+
+	var current string
+
+	err := s.withRetry(ctx, func() error {
+		now := time.Now().UTC()
+
+		return s.db.QueryRowxContext(ctx, s.db.Rebind(query), name, holder, now.Add(ttl), now).Scan(&current)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *Storage) ReleaseLease(ctx context.Context, name, holder string) error {
+	return s.exec(ctx, `delete from leases where name = ? and holder = ?`, name, holder)
+}
+
+// Connect opens the pool and pings the database until it answers, so a database starting along with
+// the service does not fail it.
 func (s *Storage) Connect(ctx context.Context) error {
-	db, err := sqlx.Open("pgx", s.dsn)
+	pool := s.config.DB.SQL
+
+	db, err := open(s.dialect, s.dsn, pool)
 	if err != nil {
 		return fmt.Errorf("connection error: %w", err)
 	}
 
+	always := func(error) bool { return true }
+	if err := retry(ctx, pool.ConnectRetries, pool.RetryBackoff, always, func() error {
+		return db.PingContext(ctx)
+	}); err != nil {
+		_ = db.Close()
+
+		return fmt.Errorf("ping database: %w", err)
+	}
+
 	s.db = db
-	s.ctx = ctx
+
+	if len(pool.Replicas) > 0 {
+		s.replicas, err = openReplicas(ctx, s.dialect, pool)
+		if err != nil {
+			_ = db.Close()
+
+			return err
+		}
+		s.replicas.watch(pool.ReplicaCheckInterval)
+	}
 
 	return nil
 }
 
+func (s *Storage) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return errNotConnected
+	}
+
+	return s.db.PingContext(ctx)
+}
+
 func (s *Storage) Close() error {
+	if s.replicas != nil {
+		if err := s.replicas.close(); err != nil {
+			_ = s.db.Close()
+
+			return fmt.Errorf("close replicas: %w", err)
+		}
+	}
+
 	return s.db.Close()
 }
 
+func open(dialect Dialect, dsn string, pool config.SQLDatabase) (*sqlx.DB, error) {
+	db, err := sqlx.Open(dialect.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// exec runs the statement, repeating it on a transient error.
+func (s *Storage) exec(ctx context.Context, query string, args ...interface{}) error {
+	return s.withRetry(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, s.db.Rebind(query), args...)
+
+		return err
+	})
+}
+
+// primary runs op on the primary database, repeating it on a transient error.
+func (s *Storage) primary(ctx context.Context, op func(db *sqlx.DB) error) error {
+	return s.withRetry(ctx, func() error {
+		return op(s.db)
+	})
+}
+
+// execEvent runs the statement on a single event like exec and reports the missing event.
+func (s *Storage) execEvent(ctx context.Context, query string, args ...interface{}) error {
+	var affected int64
+
+	err := s.withRetry(ctx, func() error {
+		result, err := s.db.ExecContext(ctx, s.db.Rebind(query), args...)
+		if err != nil {
+			return err
+		}
+
+		affected, err = result.RowsAffected()
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return errEventNotFound
+	}
+
+	return nil
+}
+
+// selectEvents runs the events query by run, which is either primary or read.
+func (s *Storage) selectEvents(
+	ctx context.Context,
+	run func(context.Context, func(*sqlx.DB) error) error,
+	query string,
+	args ...interface{},
+) (map[uuid.UUID]storage.Event, error) {
+	var list []storage.Event
+
+	err := run(ctx, func(db *sqlx.DB) error {
+		list = nil
+
+		return db.SelectContext(ctx, &list, db.Rebind(query), args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(map[uuid.UUID]storage.Event, len(list))
+	for _, event := range list {
+		events[event.ID] = event
+	}
+
+	return events, nil
+}
+
 func New(config *config.Config, dsn string) *Storage {
 	return &Storage{
-		dsn:    dsn,
-		config: *config,
-		db:     nil,
+		dsn:     dsn,
+		dialect: DialectFor(config.DB.SQL.Driver),
+		config:  *config,
+		db:      nil,
 	}
 }
@@ -0,0 +1,153 @@
+package sqlstorage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// newSQLite returns a storage on a fresh SQLite database with the sqlite migrations applied.
+func newSQLite(t *testing.T, replicas ...string) *Storage {
+	t.Helper()
+
+	cfg := config.NewConfig()
+	cfg.DB.SQL.Driver = SQLite.Driver
+	cfg.DB.SQL.Replicas = replicas
+
+	s := New(cfg, migrated(t))
+	require.NoError(t, s.Connect(context.Background()))
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// migrated creates a SQLite database with the sqlite migrations applied and returns its DSN.
+func migrated(t *testing.T) string {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "calendar.db") + "?_busy_timeout=5000&_txlock=immediate"
+
+	db, err := sqlx.Open(SQLite.Driver, dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ups, err := filepath.Glob(filepath.Join("..", "..", "..", SQLite.Migrations, "*.up.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, ups)
+	sort.Strings(ups)
+
+	for _, up := range ups {
+		migration, err := os.ReadFile(up)
+		require.NoError(t, err)
+		_, err = db.Exec(string(migration))
+		require.NoError(t, err, up)
+	}
+
+	return dsn
+}
+
+func TestSQLite(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLite(t)
+
+	start := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	event := storage.Event{
+		ID:            uuid.New(),
+		Title:         "Test title",
+		DatetimeStart: start,
+		DatetimeEnd:   start.Add(15 * time.Minute),
+		Description:   "just description",
+		UserID:        uuid.New(),
+		WhenToNotify:  start.Add(-time.Hour),
+	}
+
+	t.Run("events", func(t *testing.T) {
+		require.NoError(t, s.AddEvent(ctx, event))
+
+		got, err := s.GetEvent(ctx, event.ID)
+		require.NoError(t, err)
+		require.Equal(t, event.Title, got.Title)
+		require.True(t, event.DatetimeStart.Equal(got.DatetimeStart))
+		require.False(t, got.IsNotified)
+
+		moscow := time.FixedZone("MSK", 3*60*60)
+		events, err := s.ListEventsByRange(ctx, storage.DateRange{
+			Start: start.In(moscow).Add(-time.Minute),
+			End:   start.In(moscow).Add(time.Minute),
+		})
+		require.NoError(t, err)
+		require.Contains(t, events, event.ID)
+
+		event.Title = "Changed title"
+		require.NoError(t, s.ChangeEvent(ctx, event.ID, event))
+		got, err = s.GetEvent(ctx, event.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Changed title", got.Title)
+
+		_, err = s.GetEvent(ctx, uuid.New())
+		require.ErrorIs(t, err, errEventNotFound)
+	})
+
+	t.Run("outbox", func(t *testing.T) {
+		claimed, err := s.ClaimNotifications(ctx, start, start)
+		require.NoError(t, err)
+		require.Equal(t, 1, claimed)
+
+		claimed, err = s.ClaimNotifications(ctx, start, start)
+		require.NoError(t, err)
+		require.Zero(t, claimed)
+
+		messages, err := s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+
+		var notification storage.Notification
+		require.NoError(t, json.Unmarshal(messages[0].Payload, &notification))
+		require.Equal(t, event.ID, notification.ID)
+
+		require.NoError(t, s.DeleteOutbox(ctx, messages[0].ID))
+		messages, err = s.ListOutbox(ctx, 10)
+		require.NoError(t, err)
+		require.Empty(t, messages)
+	})
+
+	t.Run("lease", func(t *testing.T) {
+		acquired, err := s.AcquireLease(ctx, "scheduler", "first", time.Minute)
+		require.NoError(t, err)
+		require.True(t, acquired)
+
+		acquired, err = s.AcquireLease(ctx, "scheduler", "second", time.Minute)
+		require.NoError(t, err)
+		require.False(t, acquired)
+
+		require.NoError(t, s.ReleaseLease(ctx, "scheduler", "first"))
+		acquired, err = s.AcquireLease(ctx, "scheduler", "second", time.Minute)
+		require.NoError(t, err)
+		require.True(t, acquired)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, s.RemoveOldEvents(ctx, start.Add(time.Hour)))
+		_, err := s.GetEvent(ctx, event.ID)
+		require.ErrorIs(t, err, errEventNotFound)
+	})
+}
+
+func TestSQLiteConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		return newSQLite(t)
+	})
+}
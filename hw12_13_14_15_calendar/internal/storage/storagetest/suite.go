@@ -0,0 +1,331 @@
+// Package storagetest is the conformance suite every events storage runs in its tests,
+// so the backends keep the same behaviour.
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage is a storage serving both the calendar and the scheduler.
+// The notification listing and flag predate the outbox and are still kept by every backend.
+type Storage interface {
+	app.Storage
+	scheduler.Storage
+	ListEventsForNotification(ctx context.Context, datetime time.Time) (map[uuid.UUID]storage.Event, error)
+	SetIsNotified(ctx context.Context, id uuid.UUID) error
+	EachEvent(ctx context.Context, p storage.DateRange, fn func(storage.Event) error) error
+}
+
+// Factory returns a connected storage with no events, it is called for every case of the suite.
+type Factory func(t *testing.T) Storage
+
+// base is a whole second in UTC, the precision every backend keeps.
+var base = time.Date(2009, time.November, 10, 10, 0, 0, 0, time.UTC)
+
+// Run runs the suite against the storages made by factory.
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+
+	t.Run("crud", func(t *testing.T) { testCRUD(t, factory(t)) })
+	t.Run("range", func(t *testing.T) { testRange(t, factory(t)) })
+	t.Run("each", func(t *testing.T) { testEach(t, factory(t)) })
+	t.Run("notifications", func(t *testing.T) { testNotifications(t, factory(t)) })
+	t.Run("outbox", func(t *testing.T) { testOutbox(t, factory(t)) })
+	t.Run("cleanup", func(t *testing.T) { testCleanup(t, factory(t)) })
+	t.Run("lease", func(t *testing.T) { testLease(t, factory(t)) })
+}
+
+// NewEvent returns an event of a new user lasting from start till end, notified at start.
+func NewEvent(start, end time.Time) storage.Event {
+	return storage.Event{
+		ID:            uuid.New(),
+		Title:         "Test title",
+		DatetimeStart: start,
+		DatetimeEnd:   end,
+		Description:   "just description",
+		UserID:        uuid.New(),
+		WhenToNotify:  start,
+	}
+}
+
+// RequireEvent compares the events by the time instants, not their locations.
+func RequireEvent(t *testing.T, expected, actual storage.Event) {
+	t.Helper()
+
+	require.Equal(t, expected.ID, actual.ID)
+	require.Equal(t, expected.Title, actual.Title)
+	require.Equal(t, expected.Description, actual.Description)
+	require.Equal(t, expected.UserID, actual.UserID)
+	require.Equal(t, expected.IsNotified, actual.IsNotified)
+	require.Equal(t, expected.Channel, actual.Channel)
+	require.True(t, expected.DatetimeStart.Equal(actual.DatetimeStart), "start %s, got %s",
+		expected.DatetimeStart, actual.DatetimeStart)
+	require.True(t, expected.DatetimeEnd.Equal(actual.DatetimeEnd), "end %s, got %s",
+		expected.DatetimeEnd, actual.DatetimeEnd)
+	require.True(t, expected.WhenToNotify.Equal(actual.WhenToNotify), "notify %s, got %s",
+		expected.WhenToNotify, actual.WhenToNotify)
+}
+
+func testCRUD(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	event := NewEvent(base, base.Add(time.Hour))
+
+	require.NoError(t, s.AddEvent(ctx, event))
+	require.ErrorIs(t, s.AddEvent(ctx, event), storage.ErrEventExists, "the id is taken")
+
+	got, err := s.GetEvent(ctx, event.ID)
+	require.NoError(t, err)
+	RequireEvent(t, event, got)
+
+	event.Title = "Changed title"
+	event.Description = ""
+	event.DatetimeEnd = base.Add(2 * time.Hour)
+	event.Channel = "webhook"
+	require.NoError(t, s.ChangeEvent(ctx, event.ID, event))
+
+	got, err = s.GetEvent(ctx, event.ID)
+	require.NoError(t, err)
+	RequireEvent(t, event, got)
+
+	require.NoError(t, s.RemoveEvent(ctx, event.ID))
+
+	_, err = s.GetEvent(ctx, event.ID)
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+	require.ErrorIs(t, s.RemoveEvent(ctx, event.ID), storage.ErrEventNotFound)
+	require.ErrorIs(t, s.ChangeEvent(ctx, event.ID, event), storage.ErrEventNotFound)
+}
+
+func testRange(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	at := func(hour int) time.Time { return base.Add(time.Duration(hour) * time.Hour) }
+
+	morning := NewEvent(at(0), at(1))
+	noon := NewEvent(at(1), at(2))
+	conference := NewEvent(at(-48), at(72))
+	instant := NewEvent(at(5), at(5))
+	for _, event := range []storage.Event{morning, noon, conference, instant} {
+		require.NoError(t, s.AddEvent(ctx, event))
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		userID   uuid.UUID
+		expected []storage.Event
+	}{
+		{name: "start is inclusive", from: at(1), to: at(2), expected: []storage.Event{noon, conference}},
+		{name: "end is exclusive", from: at(-1), to: at(0), expected: []storage.Event{conference}},
+		{name: "event inside", from: at(-1), to: at(3), expected: []storage.Event{morning, noon, conference}},
+		{name: "range inside", from: at(0).Add(time.Minute), to: at(1).Add(-time.Minute),
+			expected: []storage.Event{morning, conference}},
+		{name: "instant at start", from: at(5), to: at(6), expected: []storage.Event{conference, instant}},
+		{name: "instant at end", from: at(4), to: at(5), expected: []storage.Event{conference}},
+		{name: "gap", from: at(73), to: at(74)},
+		{name: "user", from: at(-1), to: at(3), userID: noon.UserID, expected: []storage.Event{noon}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := s.ListEventsByRange(ctx, storage.DateRange{Start: tc.from, End: tc.to, UserID: tc.userID})
+			require.NoError(t, err)
+			require.Len(t, events, len(tc.expected))
+
+			for _, expected := range tc.expected {
+				require.Contains(t, events, expected.ID)
+				RequireEvent(t, expected, events[expected.ID])
+			}
+		})
+	}
+}
+
+func testEach(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	late := NewEvent(base.Add(2*time.Hour), base.Add(3*time.Hour))
+	early := NewEvent(base, base.Add(time.Hour))
+	other := NewEvent(base.Add(time.Hour), base.Add(2*time.Hour))
+	other.UserID = early.UserID
+	for _, event := range []storage.Event{late, early, other} {
+		require.NoError(t, s.AddEvent(ctx, event))
+	}
+
+	collect := func(p storage.DateRange) []uuid.UUID {
+		var ids []uuid.UUID
+		require.NoError(t, s.EachEvent(ctx, p, func(event storage.Event) error {
+			ids = append(ids, event.ID)
+
+			return nil
+		}))
+
+		return ids
+	}
+
+	all := storage.DateRange{Start: base.Add(-time.Hour), End: base.Add(4 * time.Hour)}
+	require.Equal(t, []uuid.UUID{early.ID, other.ID, late.ID}, collect(all), "the events go in the start order")
+
+	all.UserID = early.UserID
+	require.Equal(t, []uuid.UUID{early.ID, other.ID}, collect(all))
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := s.EachEvent(ctx, all, func(storage.Event) error {
+		calls++
+
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 1, calls)
+}
+
+func testNotifications(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	due := NewEvent(base, base.Add(time.Hour))
+	later := NewEvent(base.Add(2*time.Hour), base.Add(3*time.Hour))
+	require.NoError(t, s.AddEvent(ctx, due))
+	require.NoError(t, s.AddEvent(ctx, later))
+
+	events, err := s.ListEventsForNotification(ctx, base)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "the notification time is inclusive")
+	require.Contains(t, events, due.ID)
+
+	require.NoError(t, s.SetIsNotified(ctx, due.ID))
+	require.ErrorIs(t, s.SetIsNotified(ctx, uuid.New()), storage.ErrEventNotFound)
+
+	got, err := s.GetEvent(ctx, due.ID)
+	require.NoError(t, err)
+	require.True(t, got.IsNotified)
+
+	events, err = s.ListEventsForNotification(ctx, base.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Contains(t, events, later.ID)
+
+	// An edit carries no notified flag, the sent reminder is not sent again.
+	require.NoError(t, s.ChangeEvent(ctx, due.ID, due))
+	got, err = s.GetEvent(ctx, due.ID)
+	require.NoError(t, err)
+	require.True(t, got.IsNotified)
+
+	events, err = s.ListEventsForNotification(ctx, base)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func testOutbox(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	first := NewEvent(base, base.Add(time.Hour))
+	second := NewEvent(base.Add(time.Minute), base.Add(time.Hour))
+	later := NewEvent(base.Add(time.Hour), base.Add(2*time.Hour))
+	for _, event := range []storage.Event{first, second, later} {
+		require.NoError(t, s.AddEvent(ctx, event))
+	}
+
+	claimed, err := s.ClaimNotifications(ctx, base.Add(time.Minute), base)
+	require.NoError(t, err)
+	require.Equal(t, 2, claimed)
+
+	claimed, err = s.ClaimNotifications(ctx, base.Add(time.Minute), base)
+	require.NoError(t, err)
+	require.Zero(t, claimed, "the claimed events are notified")
+
+	messages, err := s.ListOutbox(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, messages, 1, "the limit is kept")
+
+	messages, err = s.ListOutbox(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	claimedIDs := make(map[uuid.UUID]bool)
+	for _, message := range messages {
+		var notification storage.Notification
+		require.NoError(t, json.Unmarshal(message.Payload, &notification))
+		require.Equal(t, message.ID, notification.MessageID)
+		claimedIDs[notification.ID] = true
+
+		require.NoError(t, s.DeleteOutbox(ctx, message.ID))
+	}
+	require.Equal(t, map[uuid.UUID]bool{first.ID: true, second.ID: true}, claimedIDs)
+
+	messages, err = s.ListOutbox(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, messages)
+}
+
+func testCleanup(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	old := NewEvent(base, base.Add(time.Hour))
+	edge := NewEvent(base, base.Add(2*time.Hour))
+	current := NewEvent(base, base.Add(3*time.Hour))
+	for _, event := range []storage.Event{old, edge, current} {
+		require.NoError(t, s.AddEvent(ctx, event))
+	}
+
+	require.NoError(t, s.RemoveOldEvents(ctx, base.Add(2*time.Hour)))
+
+	_, err := s.GetEvent(ctx, old.ID)
+	require.ErrorIs(t, err, storage.ErrEventNotFound)
+
+	for _, kept := range []storage.Event{edge, current} {
+		_, err := s.GetEvent(ctx, kept.ID)
+		require.NoError(t, err, "the events ending at the time or later are kept")
+	}
+}
+
+func testLease(t *testing.T, s Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	acquired, err := s.AcquireLease(ctx, "scheduler", "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, err = s.AcquireLease(ctx, "scheduler", "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired, "the holder renews the lease")
+
+	acquired, err = s.AcquireLease(ctx, "scheduler", "second", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	require.NoError(t, s.ReleaseLease(ctx, "scheduler", "second"), "releasing a foreign lease is a no-op")
+	require.NoError(t, s.ReleaseLease(ctx, "scheduler", "first"))
+
+	acquired, err = s.AcquireLease(ctx, "scheduler", "second", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	time.Sleep(10 * time.Millisecond)
+
+	acquired, err = s.AcquireLease(ctx, "scheduler", "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired, "the expired lease is taken over")
+}
@@ -0,0 +1,156 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCert(t *testing.T, dir, name string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		DNSNames:     []string{"localhost"},
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "cert.pem"), certPem, 0o600))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "key.pem"), keyPem, 0o600))
+}
+
+func currentCert(t *testing.T, r *Reloader) *x509.Certificate {
+	t.Helper()
+
+	cfg, err := r.ServerConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+
+	cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.Nil(t, err)
+
+	return parsed
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "first")
+
+	cfg := config.TLS{
+		Enabled:  true,
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+
+	r, err := NewReloader(cfg)
+	require.Nil(t, err)
+	require.Equal(t, "first", currentCert(t, r).Subject.CommonName)
+
+	writeCert(t, dir, "second")
+	require.Nil(t, r.Reload())
+	require.Equal(t, "second", currentCert(t, r).Subject.CommonName)
+
+	require.Nil(t, os.WriteFile(cfg.KeyFile, []byte("broken"), 0o600))
+	require.NotNil(t, r.Reload())
+	require.Equal(t, "second", currentCert(t, r).Subject.CommonName)
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "server")
+
+	cfg := config.TLS{
+		Enabled:      true,
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "cert.pem"),
+	}
+
+	r, err := NewReloader(cfg)
+	require.Nil(t, err)
+
+	serverCfg, err := r.ServerConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+	require.Equal(t, tls.RequireAndVerifyClientCert, serverCfg.ClientAuth)
+	require.NotNil(t, serverCfg.ClientCAs)
+
+	clientCfg, err := ClientConfig(config.ClientTLS{
+		Enabled:  true,
+		CAFile:   cfg.CertFile,
+		CertFile: cfg.CertFile,
+		KeyFile:  cfg.KeyFile,
+	})
+	require.Nil(t, err)
+	require.Len(t, clientCfg.Certificates, 1)
+	require.NotNil(t, clientCfg.RootCAs)
+
+	_, err = ClientConfig(config.ClientTLS{Enabled: true, CAFile: cfg.KeyFile})
+	require.NotNil(t, err)
+}
+
+func TestServerConfigALPN(t *testing.T) {
+	dir := t.TempDir()
+	writeCert(t, dir, "server")
+
+	r, err := NewReloader(config.TLS{
+		Enabled:  true,
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	})
+	require.Nil(t, err)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", r.ServerConfig("h2"))
+	require.Nil(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	clientCfg, err := ClientConfig(config.ClientTLS{Enabled: true, CAFile: filepath.Join(dir, "cert.pem")})
+	require.Nil(t, err)
+	clientCfg.ServerName = "localhost"
+	clientCfg.NextProtos = []string{"h2"}
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), clientCfg)
+	require.Nil(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "h2", conn.ConnectionState().NegotiatedProtocol)
+}
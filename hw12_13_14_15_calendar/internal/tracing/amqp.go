@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+)
+
+// headersCarrier adapts AMQP message headers to the propagation.TextMapCarrier.
+type headersCarrier amqp.Table
+
+func (c headersCarrier) Get(key string) string {
+	if v, ok := c[key].(string); ok {
+		return v
+	}
+
+	return ""
+}
+
+func (c headersCarrier) Set(key string, value string) {
+	c[key] = value
+}
+
+func (c headersCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// InjectHeaders puts the trace context of ctx into the message headers.
+func InjectHeaders(ctx context.Context, headers amqp.Table) amqp.Table {
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headersCarrier(headers))
+
+	return headers
+}
+
+// ExtractHeaders returns ctx continuing the trace sent in the message headers.
+func ExtractHeaders(ctx context.Context, headers amqp.Table) context.Context {
+	if headers == nil {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, headersCarrier(headers))
+}
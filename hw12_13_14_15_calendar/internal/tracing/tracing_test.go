@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHeaders(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider()
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "producer")
+	defer span.End()
+
+	headers := InjectHeaders(ctx, nil)
+	require.Contains(t, headers, "traceparent")
+
+	received := trace.SpanContextFromContext(ExtractHeaders(context.Background(), headers))
+	require.Equal(t, span.SpanContext().TraceID(), received.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), received.SpanID())
+	require.True(t, received.IsRemote())
+
+	require.Equal(t, context.Background(), ExtractHeaders(context.Background(), amqp.Table(nil)))
+}
+
+func TestExporters(t *testing.T) {
+	t.Run("stdout", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		exporter, err := newExporter(context.Background(), config.Tracing{Exporter: "stdout"}, &buf)
+		require.Nil(t, err)
+
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		_, span := provider.Tracer("test").Start(context.Background(), "app.CreateEvent")
+		span.SetAttributes(attribute.String("event.id", "1"))
+		End(span, nil)
+
+		var record struct {
+			Name       string
+			Attributes []struct{ Key string }
+		}
+		require.Nil(t, json.Unmarshal(buf.Bytes(), &record))
+		require.Equal(t, "app.CreateEvent", record.Name)
+		require.Equal(t, "event.id", record.Attributes[0].Key)
+	})
+
+	t.Run("otlp", func(t *testing.T) {
+		var req coltracepb.ExportTraceServiceRequest
+
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/traces", r.URL.Path)
+			require.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			require.Nil(t, err)
+			require.Nil(t, proto.Unmarshal(body, &req))
+		}))
+		defer collector.Close()
+
+		exporter, err := newExporter(context.Background(), config.Tracing{
+			Exporter: "otlp",
+			Endpoint: strings.TrimPrefix(collector.URL, "http://"),
+			Insecure: true,
+			Headers:  map[string]string{"x-api-key": "secret"},
+		}, nil)
+		require.Nil(t, err)
+
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		ctx, parent := provider.Tracer("test").Start(context.Background(), "scheduler.Noty")
+		_, child := provider.Tracer("test").Start(ctx, "storage.set_is_notified")
+		End(child, context.Canceled)
+		End(parent, nil)
+
+		require.Len(t, req.ResourceSpans, 1)
+		require.Len(t, req.ResourceSpans[0].ScopeSpans, 1)
+
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		require.Len(t, spans, 1)
+		require.Equal(t, "scheduler.Noty", spans[0].Name)
+		require.Equal(t, parent.SpanContext().TraceID().String(), hex.EncodeToString(spans[0].TraceId))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := newExporter(context.Background(), config.Tracing{Exporter: "jaeger"}, nil)
+		require.NotNil(t, err)
+
+		_, err = newExporter(context.Background(), config.Tracing{Exporter: "otlp"}, nil)
+		require.NotNil(t, err)
+	})
+}
@@ -0,0 +1,135 @@
+// Package transfer exports the events of a storage to JSON Lines and imports them back,
+// one event at a time, so the size of a calendar is not limited by the memory.
+package transfer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Conflict is the policy for an imported event whose ID is already taken.
+type Conflict string
+
+const (
+	Skip      Conflict = "skip"
+	Overwrite Conflict = "overwrite"
+	Fail      Conflict = "fail"
+)
+
+var (
+	ErrConflict    = errors.New("event already exists")
+	errBadConflict = errors.New(`the conflict policy must be "skip", "overwrite" or "fail"`)
+)
+
+// AllTime is the range of every event the storages keep.
+var AllTime = storage.DateRange{
+	Start: time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC),
+	End:   time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+}
+
+// Storage is what the export and import need from an events storage.
+type Storage interface {
+	AddEvent(ctx context.Context, event storage.Event) error
+	ChangeEvent(ctx context.Context, id uuid.UUID, event storage.Event) error
+	GetEvent(ctx context.Context, id uuid.UUID) (storage.Event, error)
+	SetIsNotified(ctx context.Context, id uuid.UUID) error
+	EachEvent(ctx context.Context, p storage.DateRange, fn func(storage.Event) error) error
+	Connect(ctx context.Context) error
+	Close() error
+}
+
+// Result counts the imported events.
+type Result struct {
+	Added       int
+	Overwritten int
+	Skipped     int
+}
+
+func ParseConflict(policy string) (Conflict, error) {
+	switch conflict := Conflict(policy); conflict {
+	case Skip, Overwrite, Fail:
+		return conflict, nil
+	}
+
+	return "", errBadConflict
+}
+
+// Export writes the events in the range to w, an event per line, and returns their number.
+func Export(ctx context.Context, s Storage, p storage.DateRange, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	exported := 0
+
+	err := s.EachEvent(ctx, p, func(event storage.Event) error {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("write event %s: %w", event.ID, err)
+		}
+		exported++
+
+		return nil
+	})
+
+	return exported, err
+}
+
+// Import adds the events read from r, resolving the taken IDs by the policy. It stops at the first error,
+// the events imported before it are kept.
+func Import(ctx context.Context, s Storage, r io.Reader, policy Conflict) (Result, error) {
+	var result Result
+
+	decoder := json.NewDecoder(r)
+
+	for line := 1; ; line++ {
+		var event storage.Event
+
+		err := decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, fmt.Errorf("read event %d: %w", line, err)
+		}
+
+		if err := importEvent(ctx, s, event, policy, &result); err != nil {
+			return result, fmt.Errorf("import event %d: %w", line, err)
+		}
+	}
+}
+
+func importEvent(ctx context.Context, s Storage, event storage.Event, policy Conflict, result *Result) error {
+	_, err := s.GetEvent(ctx, event.ID)
+
+	switch {
+	case errors.Is(err, storage.ErrEventNotFound):
+		if err := s.AddEvent(ctx, event); err != nil {
+			return err
+		}
+		result.Added++
+	case err != nil:
+		return err
+	case policy == Skip:
+		result.Skipped++
+
+		return nil
+	case policy == Overwrite:
+		if err := s.ChangeEvent(ctx, event.ID, event); err != nil {
+			return err
+		}
+		result.Overwritten++
+	default:
+		return fmt.Errorf("%s: %w", event.ID, ErrConflict)
+	}
+
+	// The storages add the events not notified and keep the flag on a change, only SetIsNotified sets it.
+	if event.IsNotified {
+		return s.SetIsNotified(ctx, event.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/storagetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2009, time.November, 10, 10, 0, 0, 0, time.UTC)
+
+	first := storagetest.NewEvent(base, base.Add(time.Hour))
+	second := storagetest.NewEvent(base.Add(24*time.Hour), base.Add(25*time.Hour))
+	second.IsNotified = true
+
+	source := memorystorage.New()
+	require.NoError(t, source.AddEvent(ctx, first))
+	require.NoError(t, source.AddEvent(ctx, second))
+
+	var all bytes.Buffer
+	exported, err := Export(ctx, source, AllTime, &all)
+	require.NoError(t, err)
+	require.Equal(t, 2, exported)
+	require.Equal(t, 2, strings.Count(all.String(), "\n"), "an event per line")
+
+	var subset bytes.Buffer
+	exported, err = Export(ctx, source, storage.DateRange{Start: base, End: base.Add(time.Hour)}, &subset)
+	require.NoError(t, err)
+	require.Equal(t, 1, exported)
+
+	t.Run("into an empty storage", func(t *testing.T) {
+		target := memorystorage.New()
+		result, err := Import(ctx, target, bytes.NewReader(all.Bytes()), Fail)
+		require.NoError(t, err)
+		require.Equal(t, Result{Added: 2}, result)
+
+		got, err := target.GetEvent(ctx, second.ID)
+		require.NoError(t, err)
+		storagetest.RequireEvent(t, second, got)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		changed := first
+		changed.Title = "changed"
+
+		target := memorystorage.New()
+		require.NoError(t, target.AddEvent(ctx, changed))
+
+		_, err := Import(ctx, target, bytes.NewReader(all.Bytes()), Fail)
+		require.ErrorIs(t, err, ErrConflict)
+
+		result, err := Import(ctx, target, bytes.NewReader(all.Bytes()), Skip)
+		require.NoError(t, err)
+		require.Equal(t, Result{Added: 1, Skipped: 1}, result)
+
+		got, err := target.GetEvent(ctx, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, "changed", got.Title)
+
+		result, err = Import(ctx, target, bytes.NewReader(all.Bytes()), Overwrite)
+		require.NoError(t, err)
+		require.Equal(t, Result{Overwritten: 2}, result)
+
+		got, err = target.GetEvent(ctx, first.ID)
+		require.NoError(t, err)
+		storagetest.RequireEvent(t, first, got)
+
+		// The overwrite keeps the notified flag, the sent reminder is not sent again.
+		require.NoError(t, target.SetIsNotified(ctx, first.ID))
+		_, err = Import(ctx, target, bytes.NewReader(all.Bytes()), Overwrite)
+		require.NoError(t, err)
+
+		got, err = target.GetEvent(ctx, first.ID)
+		require.NoError(t, err)
+		require.True(t, got.IsNotified)
+	})
+
+	t.Run("bad line", func(t *testing.T) {
+		target := memorystorage.New()
+		input := subset.String() + "{broken\n"
+
+		result, err := Import(ctx, target, strings.NewReader(input), Fail)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read event 2")
+		require.Equal(t, 1, result.Added)
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		policy, err := ParseConflict("skip")
+		require.NoError(t, err)
+		require.Equal(t, Skip, policy)
+
+		_, err = ParseConflict("merge")
+		require.ErrorIs(t, err, errBadConflict)
+	})
+}
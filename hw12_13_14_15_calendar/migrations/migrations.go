@@ -0,0 +1,7 @@
+// Package migrations embeds the database migrations, a directory per SQL dialect.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,9 @@
+create table if not exists outbox
+(
+    id         uuid primary key,
+    event_id   uuid        not null,
+    payload    jsonb       not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists outbox_created_at_idx on outbox (created_at);
@@ -0,0 +1,6 @@
+create table if not exists leases
+(
+    name       varchar(64) primary key,
+    holder     varchar(256) not null,
+    expires_at timestamptz  not null
+);
@@ -0,0 +1,2 @@
+create index if not exists events_user_range_idx on events (user_id, datetime_start, datetime_end);
+create index if not exists events_range_idx on events (datetime_start, datetime_end);
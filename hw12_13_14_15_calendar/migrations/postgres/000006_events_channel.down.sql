@@ -0,0 +1,3 @@
+alter table if exists events
+    drop column channel
+;
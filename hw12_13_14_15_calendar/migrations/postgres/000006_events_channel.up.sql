@@ -0,0 +1,3 @@
+alter table if exists events
+    add column channel varchar(32) default '' not null
+;
@@ -0,0 +1,11 @@
+create table if not exists events
+(
+    id             text primary key,
+    title          text      not null,
+    datetime_start timestamp not null,
+    datetime_end   timestamp not null,
+    description    text      not null default '',
+    user_id        text      not null,
+    when_to_notify timestamp not null,
+    is_notified    boolean   not null default false
+);
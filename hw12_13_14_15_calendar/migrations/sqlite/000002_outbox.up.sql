@@ -0,0 +1,9 @@
+create table if not exists outbox
+(
+    id         text primary key,
+    event_id   text      not null,
+    payload    blob      not null,
+    created_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+create index if not exists outbox_created_at_idx on outbox (created_at);
@@ -0,0 +1,6 @@
+create table if not exists leases
+(
+    name       text primary key,
+    holder     text      not null,
+    expires_at timestamp not null
+);
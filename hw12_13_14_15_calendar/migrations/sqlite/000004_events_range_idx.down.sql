@@ -0,0 +1,2 @@
+drop index if exists events_range_idx;
+drop index if exists events_user_range_idx;
@@ -0,0 +1 @@
+alter table events add column channel text not null default '';
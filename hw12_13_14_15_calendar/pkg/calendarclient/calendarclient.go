@@ -0,0 +1,178 @@
+// Package calendarclient is a client of the calendar service. The same Client works over the HTTP API
+// and over the gRPC API, it retries the calls the server turned down as unavailable.
+package calendarclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// Event is a calendar event, the reminder is sent at WhenToNotify.
+type Event struct {
+	ID           uuid.UUID
+	Title        string
+	Start        time.Time
+	End          time.Time
+	Description  string
+	UserID       uuid.UUID
+	WhenToNotify time.Time
+}
+
+// Period is the length of a listed period.
+type Period string
+
+const (
+	Day   Period = "day"
+	Week  Period = "week"
+	Month Period = "month"
+)
+
+type Client interface {
+	// Create adds the event, a new ID is given to an event without one. It returns the added event.
+	// A taken ID fails with ErrConflict, unless the call was retried: the event is taken as added by it then.
+	Create(ctx context.Context, event Event) (Event, error)
+	// Update replaces the event with the same ID.
+	Update(ctx context.Context, event Event) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	Get(ctx context.Context, id uuid.UUID) (Event, error)
+	// List returns the events of the period starting at the day of date, ordered by the start.
+	List(ctx context.Context, period Period, date time.Time) ([]Event, error)
+	Close() error
+}
+
+// Options are the credentials, the transport and the retries of a client.
+type Options struct {
+	// Token is the bearer token of the user, APIKey is the alternative.
+	Token  string
+	APIKey string
+	// TLS enables HTTPS or gRPC over TLS, the plain protocol is used when it is nil.
+	TLS *tls.Config
+	// Retries is the number of retries of a call turned down as unavailable, none when zero.
+	// Backoff is the first pause between the attempts, it doubles after every retry.
+	Retries int
+	Backoff time.Duration
+	// HTTPClient replaces the client of the HTTP transport, TLS is ignored then.
+	HTTPClient *http.Client
+}
+
+var (
+	ErrNotFound        = errors.New("event not found")
+	ErrForbidden       = errors.New("access to the event is forbidden")
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrBadRequest      = errors.New("bad request")
+	ErrConflict        = errors.New("event already exists")
+	ErrUnavailable     = errors.New("service unavailable")
+	ErrServer          = errors.New("server error")
+
+	errBadPeriod = errors.New(`the period must be "day", "week" or "month"`)
+)
+
+// Error is a call the server failed. Kind is one of the Err values, errors.Is matches it.
+// RetryAfter is the pause the server asked for, zero when it did not.
+type Error struct {
+	Kind       error
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("calendar: %s: %s", e.Kind, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func checkPeriod(period Period) error {
+	switch period {
+	case Day, Week, Month:
+		return nil
+	}
+
+	return errBadPeriod
+}
+
+func sortEvents(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Start.Equal(events[j].Start) {
+			return events[i].Start.Before(events[j].Start)
+		}
+
+		return events[i].ID.String() < events[j].ID.String()
+	})
+}
+
+type retrier struct {
+	retries int
+	backoff time.Duration
+}
+
+func newRetrier(opts Options) retrier {
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	return retrier{retries: opts.Retries, backoff: backoff}
+}
+
+// created treats a conflict of a repeated create as success: the attempt turned down by a gateway
+// may have added the event before the response was lost.
+func created(op func() error) func() error {
+	attempt := 0
+
+	return func() error {
+		attempt++
+
+		err := op()
+		if attempt > 1 && errors.Is(err, ErrConflict) {
+			return nil
+		}
+
+		return err
+	}
+}
+
+// do runs op until it is not turned down as unavailable, the retries run out or ctx is done.
+func (r retrier) do(ctx context.Context, op func() error) error {
+	backoff := r.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := op()
+
+		var callErr *Error
+		if attempt >= r.retries || !errors.As(err, &callErr) || !errors.Is(callErr.Kind, ErrUnavailable) {
+			return err
+		}
+
+		wait := backoff
+		if callErr.RetryAfter > wait {
+			wait = callErr.RetryAfter
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		}
+
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
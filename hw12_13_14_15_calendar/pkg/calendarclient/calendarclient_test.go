@@ -0,0 +1,224 @@
+package calendarclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/app"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
+	internalgrpc "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
+	internalhttp "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type logger struct{}
+
+func (logger) Error(...interface{})                                                         {}
+func (logger) Info(...interface{})                                                          {}
+func (logger) LogHTTPRequest(*http.Request, time.Duration, int)                             {}
+func (logger) LogGRPCRequest(context.Context, *grpc.UnaryServerInfo, time.Duration, string) {}
+
+func newApp() *app.App {
+	return app.New(memorystorage.New(), &config.Config{})
+}
+
+func TestHTTP(t *testing.T) {
+	cfg := &config.Config{}
+	server := httptest.NewServer(internalhttp.NewServer(logger{}, newApp(), nil, nil, cfg).Handler())
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL, Options{})
+	require.NoError(t, err)
+	defer client.Close()
+
+	testClient(t, client)
+}
+
+func TestGRPC(t *testing.T) {
+	cfg := &config.Config{}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	internalgrpc.RegisterEventServiceServer(server, internalgrpc.NewGRPCServer(logger{}, newApp(), nil, nil, cfg))
+	go func() { _ = server.Serve(l) }()
+	defer server.Stop()
+
+	client, err := NewGRPC(l.Addr().String(), Options{})
+	require.NoError(t, err)
+	defer client.Close()
+
+	testClient(t, client)
+}
+
+func testClient(t *testing.T, client Client) {
+	t.Helper()
+
+	ctx := context.Background()
+	start := time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC)
+
+	late, err := client.Create(ctx, Event{
+		Title:        "late",
+		Start:        start.Add(2 * time.Hour),
+		End:          start.Add(3 * time.Hour),
+		UserID:       uuid.New(),
+		WhenToNotify: start,
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, late.ID)
+
+	early := Event{
+		ID:           uuid.New(),
+		Title:        "early",
+		Start:        start,
+		End:          start.Add(time.Hour),
+		Description:  "desc",
+		UserID:       late.UserID,
+		WhenToNotify: start.Add(-time.Hour),
+	}
+	_, err = client.Create(ctx, early)
+	require.NoError(t, err)
+
+	got, err := client.Get(ctx, early.ID)
+	require.NoError(t, err)
+	requireEvent(t, early, got)
+
+	events, err := client.List(ctx, Day, start)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	requireEvent(t, early, events[0])
+	requireEvent(t, late, events[1])
+
+	events, err = client.List(ctx, Week, start.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	early.Title = "updated"
+	require.NoError(t, client.Update(ctx, early))
+
+	got, err = client.Get(ctx, early.ID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", got.Title)
+
+	require.NoError(t, client.Delete(ctx, early.ID))
+
+	_, err = client.Get(ctx, early.ID)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.ErrorIs(t, client.Delete(ctx, early.ID), ErrNotFound)
+
+	_, err = client.List(ctx, "year", start)
+	require.Error(t, err)
+}
+
+func requireEvent(t *testing.T, expected, actual Event) {
+	t.Helper()
+
+	require.Equal(t, expected.ID, actual.ID)
+	require.Equal(t, expected.Title, actual.Title)
+	require.Equal(t, expected.Description, actual.Description)
+	require.Equal(t, expected.UserID, actual.UserID)
+	require.True(t, expected.Start.Equal(actual.Start))
+	require.True(t, expected.End.Equal(actual.End))
+	require.True(t, expected.WhenToNotify.Equal(actual.WhenToNotify))
+}
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"Status":200,"Message":"ok"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL, Options{Retries: 2, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	require.NoError(t, client.Delete(context.Background(), uuid.New()))
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	client, err = NewHTTP(server.URL, Options{Retries: 1, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	err = client.Delete(context.Background(), uuid.New())
+	require.ErrorIs(t, err, ErrUnavailable)
+
+	var callErr *Error
+	require.True(t, errors.As(err, &callErr))
+	require.Equal(t, "Service Unavailable", callErr.Message)
+	require.Equal(t, 2, calls)
+}
+
+func TestRetriedCreate(t *testing.T) {
+	created := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request eventRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		if created[request.ID] {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"Status":409,"Message":"event already exists"}`))
+
+			return
+		}
+
+		// The event is added, but the gateway loses the response.
+		created[request.ID] = true
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL, Options{Retries: 1, Backoff: time.Millisecond})
+	require.NoError(t, err)
+
+	event, err := client.Create(context.Background(), Event{Title: "standup"})
+	require.NoError(t, err, "the retry finds the event added by the first attempt")
+	require.True(t, created[event.ID.String()])
+
+	_, err = client.Create(context.Background(), event)
+	require.ErrorIs(t, err, ErrConflict, "the first attempt finds the id taken")
+}
+
+func TestAuthErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"Status":401,"Message":"unauthorized"}`))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"Status":403,"Message":"forbidden"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTP(server.URL, Options{})
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background(), uuid.New())
+	require.ErrorIs(t, err, ErrUnauthenticated)
+
+	client, err = NewHTTP(server.URL, Options{Token: "secret"})
+	require.NoError(t, err)
+
+	_, err = client.Get(context.Background(), uuid.New())
+	require.ErrorIs(t, err, ErrForbidden)
+	require.EqualError(t, err, "calendar: access to the event is forbidden: forbidden")
+}
@@ -0,0 +1,240 @@
+package calendarclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	internalgrpc "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/grpc"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var codeKinds = map[codes.Code]error{
+	codes.InvalidArgument:   ErrBadRequest,
+	codes.OutOfRange:        ErrBadRequest,
+	codes.Unauthenticated:   ErrUnauthenticated,
+	codes.PermissionDenied:  ErrForbidden,
+	codes.NotFound:          ErrNotFound,
+	codes.AlreadyExists:     ErrConflict,
+	codes.Unavailable:       ErrUnavailable,
+	codes.ResourceExhausted: ErrUnavailable,
+}
+
+type grpcClient struct {
+	conn   *grpc.ClientConn
+	client internalgrpc.EventServiceClient
+	opts   Options
+	retry  retrier
+}
+
+// NewGRPC is a client of the gRPC API at addr, e.g. localhost:8081. The connection is made on the first call.
+func NewGRPC(addr string, opts Options) (Client, error) {
+	creds := grpc.WithInsecure()
+	if opts.TLS != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(opts.TLS))
+	}
+
+	conn, err := grpc.Dial(addr, creds)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+
+	return &grpcClient{
+		conn:   conn,
+		client: internalgrpc.NewEventServiceClient(conn),
+		opts:   opts,
+		retry:  newRetrier(opts),
+	}, nil
+}
+
+func (c *grpcClient) Create(ctx context.Context, event Event) (Event, error) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+
+	err := c.retry.do(ctx, created(func() error {
+		var header metadata.MD
+		_, err := c.client.Create(c.withCredentials(ctx), toProto(event), grpc.Header(&header))
+
+		return grpcError(err, header)
+	}))
+	if err != nil {
+		return Event{}, err
+	}
+
+	return event, nil
+}
+
+func (c *grpcClient) Update(ctx context.Context, event Event) error {
+	return c.retry.do(ctx, func() error {
+		var header metadata.MD
+		_, err := c.client.Update(c.withCredentials(ctx), toProto(event), grpc.Header(&header))
+
+		return grpcError(err, header)
+	})
+}
+
+func (c *grpcClient) Delete(ctx context.Context, id uuid.UUID) error {
+	return c.retry.do(ctx, func() error {
+		var header metadata.MD
+		request := &internalgrpc.DeleteRequest{Id: id.String()}
+		_, err := c.client.Delete(c.withCredentials(ctx), request, grpc.Header(&header))
+
+		return grpcError(err, header)
+	})
+}
+
+func (c *grpcClient) Get(ctx context.Context, id uuid.UUID) (Event, error) {
+	var event Event
+
+	err := c.retry.do(ctx, func() error {
+		var header metadata.MD
+		request := &internalgrpc.GetRequest{Id: id.String()}
+		resp, err := c.client.Get(c.withCredentials(ctx), request, grpc.Header(&header))
+		if err != nil {
+			return grpcError(err, header)
+		}
+
+		event, err = fromProto(resp)
+
+		return err
+	})
+
+	return event, err
+}
+
+func (c *grpcClient) List(ctx context.Context, period Period, date time.Time) ([]Event, error) {
+	var call func(context.Context, *internalgrpc.DateRequest, ...grpc.CallOption) (*internalgrpc.EventsResponse, error)
+
+	switch period {
+	case Day:
+		call = c.client.EventListOfDay
+	case Week:
+		call = c.client.EventListOfWeek
+	case Month:
+		call = c.client.EventListOfMonth
+	default:
+		return nil, errBadPeriod
+	}
+
+	var events []Event
+
+	err := c.retry.do(ctx, func() error {
+		var header metadata.MD
+		request := &internalgrpc.DateRequest{Date: date.Format("2006-01-02")}
+		resp, err := call(c.withCredentials(ctx), request, grpc.Header(&header))
+		if err != nil {
+			return grpcError(err, header)
+		}
+
+		events = make([]Event, 0, len(resp.Events))
+		for _, e := range resp.Events {
+			event, err := fromProto(e)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortEvents(events)
+
+	return events, nil
+}
+
+func (c *grpcClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *grpcClient) withCredentials(ctx context.Context) context.Context {
+	if c.opts.Token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.opts.Token)
+	}
+
+	if c.opts.APIKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", c.opts.APIKey)
+	}
+
+	return ctx
+}
+
+// grpcError converts a status to an Error, the errors of the context are returned as they are.
+func grpcError(err error, header metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	if st.Code() == codes.Canceled {
+		return context.Canceled
+	}
+	if st.Code() == codes.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+
+	callErr := &Error{Kind: ErrServer, Message: st.Message()}
+	if kind, ok := codeKinds[st.Code()]; ok {
+		callErr.Kind = kind
+	}
+
+	if errors.Is(callErr.Kind, ErrUnavailable) {
+		if values := header.Get("retry-after"); len(values) > 0 {
+			if seconds, err := strconv.Atoi(values[0]); err == nil {
+				callErr.RetryAfter = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	return callErr
+}
+
+func toProto(event Event) *internalgrpc.Event {
+	return &internalgrpc.Event{
+		Id:            event.ID.String(),
+		Title:         event.Title,
+		DatetimeStart: event.Start.Format(time.RFC3339),
+		DatetimeEnd:   event.End.Format(time.RFC3339),
+		Description:   event.Description,
+		UserId:        event.UserID.String(),
+		WhenToNotify:  event.WhenToNotify.Format(time.RFC3339),
+	}
+}
+
+func fromProto(e *internalgrpc.Event) (Event, error) {
+	event := Event{Title: e.Title, Description: e.Description}
+
+	var err error
+	if event.ID, err = uuid.Parse(e.Id); err != nil {
+		return Event{}, fmt.Errorf("bad event id: %w", err)
+	}
+	if event.UserID, err = uuid.Parse(e.UserId); err != nil {
+		return Event{}, fmt.Errorf("bad user id of event %s: %w", e.Id, err)
+	}
+	if event.Start, err = time.Parse(time.RFC3339, e.DatetimeStart); err != nil {
+		return Event{}, fmt.Errorf("bad start of event %s: %w", e.Id, err)
+	}
+	if event.End, err = time.Parse(time.RFC3339, e.DatetimeEnd); err != nil {
+		return Event{}, fmt.Errorf("bad end of event %s: %w", e.Id, err)
+	}
+	if event.WhenToNotify, err = time.Parse(time.RFC3339, e.WhenToNotify); err != nil {
+		return Event{}, fmt.Errorf("bad reminder time of event %s: %w", e.Id, err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,258 @@
+package calendarclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type httpClient struct {
+	base   string
+	client *http.Client
+	opts   Options
+	retry  retrier
+}
+
+// eventRequest is the body of the create and update requests.
+type eventRequest struct {
+	ID     string
+	Title  string
+	Start  string
+	End    string
+	Desc   string
+	UserID string
+	When   string
+}
+
+// storedEvent is an event of the get and list responses.
+type storedEvent struct {
+	ID            uuid.UUID
+	Title         string
+	DatetimeStart time.Time
+	DatetimeEnd   time.Time
+	Description   string
+	UserID        uuid.UUID
+	WhenToNotify  time.Time
+}
+
+type typicalResponse struct {
+	Status  int
+	Message string
+}
+
+// NewHTTP is a client of the HTTP API at baseURL, e.g. http://localhost:8080.
+func NewHTTP(baseURL string, opts Options) (Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("bad base url %q: the scheme must be http or https", baseURL)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = opts.TLS
+		client = &http.Client{Transport: transport}
+	}
+
+	return &httpClient{
+		base:   strings.TrimSuffix(baseURL, "/"),
+		client: client,
+		opts:   opts,
+		retry:  newRetrier(opts),
+	}, nil
+}
+
+func (c *httpClient) Create(ctx context.Context, event Event) (Event, error) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+
+	err := c.retry.do(ctx, created(func() error {
+		return c.call(ctx, http.MethodPost, "/events/create", toRequest(event), nil)
+	}))
+	if err != nil {
+		return Event{}, err
+	}
+
+	return event, nil
+}
+
+func (c *httpClient) Update(ctx context.Context, event Event) error {
+	return c.retry.do(ctx, func() error {
+		return c.call(ctx, http.MethodPatch, "/events/"+event.ID.String(), toRequest(event), nil)
+	})
+}
+
+func (c *httpClient) Delete(ctx context.Context, id uuid.UUID) error {
+	return c.retry.do(ctx, func() error {
+		return c.call(ctx, http.MethodDelete, "/events/"+id.String(), nil, nil)
+	})
+}
+
+func (c *httpClient) Get(ctx context.Context, id uuid.UUID) (Event, error) {
+	var stored storedEvent
+
+	err := c.retry.do(ctx, func() error {
+		return c.call(ctx, http.MethodGet, "/events/"+id.String(), nil, &stored)
+	})
+	if err != nil {
+		return Event{}, err
+	}
+
+	return stored.event(), nil
+}
+
+func (c *httpClient) List(ctx context.Context, period Period, date time.Time) ([]Event, error) {
+	if err := checkPeriod(period); err != nil {
+		return nil, err
+	}
+
+	var stored map[uuid.UUID]storedEvent
+
+	path := fmt.Sprintf("/events/%s/%s", period, date.Format("2006/01/02"))
+	err := c.retry.do(ctx, func() error {
+		return c.call(ctx, http.MethodGet, path, nil, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0, len(stored))
+	for _, e := range stored {
+		events = append(events, e.event())
+	}
+	sortEvents(events)
+
+	return events, nil
+}
+
+func (c *httpClient) Close() error {
+	c.client.CloseIdleConnections()
+
+	return nil
+}
+
+// call sends body as JSON and decodes the response of a successful call to result, if it is not nil.
+func (c *httpClient) call(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	}
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-API-Key", c.opts.APIKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return dialError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// dialError marks a request that did not reach the server as unavailable, so it is retried.
+func dialError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return &Error{Kind: ErrUnavailable, Message: opErr.Error()}
+	}
+
+	return err
+}
+
+func statusError(resp *http.Response) error {
+	message := http.StatusText(resp.StatusCode)
+
+	var typical typicalResponse
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16)); err == nil && json.Unmarshal(data, &typical) == nil {
+		message = typical.Message
+	}
+
+	callErr := &Error{Kind: ErrServer, Message: message}
+
+	switch resp.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		callErr.Kind = ErrBadRequest
+	case http.StatusUnauthorized:
+		callErr.Kind = ErrUnauthenticated
+	case http.StatusForbidden:
+		callErr.Kind = ErrForbidden
+	case http.StatusNotFound:
+		callErr.Kind = ErrNotFound
+	case http.StatusConflict:
+		callErr.Kind = ErrConflict
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		callErr.Kind = ErrUnavailable
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			callErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return callErr
+}
+
+func toRequest(event Event) eventRequest {
+	return eventRequest{
+		ID:     event.ID.String(),
+		Title:  event.Title,
+		Start:  event.Start.Format(time.RFC3339),
+		End:    event.End.Format(time.RFC3339),
+		Desc:   event.Description,
+		UserID: event.UserID.String(),
+		When:   event.WhenToNotify.Format(time.RFC3339),
+	}
+}
+
+func (e storedEvent) event() Event {
+	return Event{
+		ID:           e.ID,
+		Title:        e.Title,
+		Start:        e.DatetimeStart,
+		End:          e.DatetimeEnd,
+		Description:  e.Description,
+		UserID:       e.UserID,
+		WhenToNotify: e.WhenToNotify,
+	}
+}
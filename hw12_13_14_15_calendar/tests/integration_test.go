@@ -4,30 +4,36 @@
 package tests
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/config"
-	internalhttp "github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/server/http"
-	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/internal/storage"
+	"github.com/LightAir/otus_home_work/hw12_13_14_15_calendar/pkg/calendarclient"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 )
 
 type httpTestSuite struct {
 	suite.Suite
-	host  string
-	port  string
-	event *internalhttp.EventRequest
+	host string
+	port string
+}
+
+// clientTestSuite runs the calendar scenarios through the client of a transport.
+type clientTestSuite struct {
+	suite.Suite
+	connect func(cfg *config.Config) (calendarclient.Client, error)
+	client  calendarclient.Client
+	event   calendarclient.Event
 }
 
 var configFile string
@@ -36,36 +42,38 @@ func init() {
 	flag.StringVar(&configFile, "config", "/etc/calendar/config-test.yaml", "Path to configuration file")
 }
 
-func TestHttpTestSuite(t *testing.T) {
-	suite.Run(t, &httpTestSuite{})
-}
-
-func (s *httpTestSuite) SetupSuite() {
+func loadConfig() *config.Config {
 	flag.Parse()
 
-	cfg, err := config.Parse(configFile)
+	cfg, err := config.Load(configFile, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	s.host = cfg.Server.Host
-	s.port = cfg.Server.Port
+	return cfg
 }
 
-func (s *httpTestSuite) SetupTest() {
-	s.event = &internalhttp.EventRequest{
-		ID:     "14670ec6-dbca-425b-a4c7-d13c269af380",
-		Title:  "test",
-		Start:  "2009-10-31T23:59:59Z",
-		End:    "2010-01-01T08:00:00Z",
-		Desc:   "new year",
-		UserID: "9591d712-1b3e-4495-bb71-08c906273a09",
-		When:   "2009-12-31T23:59:59Z",
-	}
+func TestHttpTestSuite(t *testing.T) {
+	suite.Run(t, &httpTestSuite{})
+}
+
+func TestHTTPClientSuite(t *testing.T) {
+	suite.Run(t, &clientTestSuite{connect: func(cfg *config.Config) (calendarclient.Client, error) {
+		return calendarclient.NewHTTP("http://"+net.JoinHostPort(cfg.Server.Host, cfg.Server.Port), calendarclient.Options{})
+	}})
 }
 
-func (s *httpTestSuite) TearDownTest() {
-	s.event = nil
+func TestGRPCClientSuite(t *testing.T) {
+	suite.Run(t, &clientTestSuite{connect: func(cfg *config.Config) (calendarclient.Client, error) {
+		return calendarclient.NewGRPC(net.JoinHostPort(cfg.GRPCServer.Host, cfg.GRPCServer.Port), calendarclient.Options{})
+	}})
+}
+
+func (s *httpTestSuite) SetupSuite() {
+	cfg := loadConfig()
+
+	s.host = cfg.Server.Host
+	s.port = cfg.Server.Port
 }
 
 func (s *httpTestSuite) req(method, path string, body io.Reader) *http.Response {
@@ -87,47 +95,6 @@ func (s *httpTestSuite) getBody(response *http.Response) string {
 	return strings.Trim(string(byteBody), "\n")
 }
 
-func (s *httpTestSuite) generatePath(period string) string {
-	date, err := time.Parse(time.RFC3339, s.event.Start)
-	s.NoError(err)
-
-	return fmt.Sprintf("events/%s/%s", period, date.Format("2006/01/02"))
-}
-
-func (s *httpTestSuite) getEventResponse() (string, error) {
-	dateStart, err := time.Parse(time.RFC3339, s.event.Start)
-	if err != nil {
-		return "", err
-	}
-
-	dateEnd, err := time.Parse(time.RFC3339, s.event.End)
-	if err != nil {
-		return "", err
-	}
-
-	dateWhen, err := time.Parse(time.RFC3339, s.event.When)
-	if err != nil {
-		return "", err
-	}
-
-	eventResponse := storage.Event{
-		ID:            uuid.MustParse(s.event.ID),
-		Title:         s.event.Title,
-		DatetimeStart: dateStart,
-		DatetimeEnd:   dateEnd,
-		Description:   s.event.Desc,
-		UserID:        uuid.MustParse(s.event.UserID),
-		WhenToNotify:  dateWhen,
-	}
-
-	eventResponseByte, err := json.Marshal(eventResponse)
-	if err != nil {
-		return "", err
-	}
-
-	return string(eventResponseByte), nil
-}
-
 func (s *httpTestSuite) TestPing() {
 	response := s.req(http.MethodGet, "", nil)
 	defer response.Body.Close()
@@ -140,7 +107,7 @@ func (s *httpTestSuite) TestCreateEmptyBody() {
 	response := s.req(http.MethodPost, "events/create", nil)
 	defer response.Body.Close()
 
-	s.Equal(http.StatusOK, response.StatusCode)
+	s.Equal(http.StatusBadRequest, response.StatusCode)
 	s.Equal(`{"Status":400,"Message":"failed to unmarshal request body"}`, s.getBody(response))
 }
 
@@ -149,111 +116,92 @@ func (s *httpTestSuite) TestCreateBadBody() {
 	response := s.req(http.MethodPost, "events/create", strings.NewReader(reqStr))
 	defer response.Body.Close()
 
-	s.Equal(http.StatusOK, response.StatusCode)
+	s.Equal(http.StatusInternalServerError, response.StatusCode)
 	s.Equal(`{"Status":500,"Message":"internal server error"}`, s.getBody(response))
 }
 
-func (s *httpTestSuite) TestFull() {
-	reqBytes, err := json.Marshal(s.event)
-	s.NoError(err)
-
-	// create
-	response := s.req(http.MethodPost, "events/create", bytes.NewReader(reqBytes))
-
-	s.Equal(http.StatusOK, response.StatusCode)
-	s.Equal(`{"Status":200,"Message":"event was created"}`, s.getBody(response))
-	response.Body.Close()
-
-	// double
-	response = s.req(http.MethodPost, "events/create", bytes.NewReader(reqBytes))
-
-	s.Equal(http.StatusOK, response.StatusCode)
-	s.Equal(`{"Status":500,"Message":"internal server error"}`, s.getBody(response))
-	response.Body.Close()
-
-	// day
-	pathDay := s.generatePath("day")
-	response = s.req(http.MethodGet, pathDay, bytes.NewReader(reqBytes))
+func (s *clientTestSuite) SetupSuite() {
+	client, err := s.connect(loadConfig())
+	s.Require().NoError(err)
 
-	s.Equal(http.StatusOK, response.StatusCode)
-
-	eventResponse, err := s.getEventResponse()
-	s.NoError(err)
+	s.client = client
+}
 
-	s.Contains(s.getBody(response), eventResponse)
-	response.Body.Close()
+func (s *clientTestSuite) TearDownSuite() {
+	s.NoError(s.client.Close())
+}
 
-	// week
-	pathWeek := s.generatePath("week")
-	response = s.req(http.MethodGet, pathWeek, bytes.NewReader(reqBytes))
+func (s *clientTestSuite) SetupTest() {
+	s.event = calendarclient.Event{
+		ID:           uuid.New(),
+		Title:        "test",
+		Start:        time.Date(2009, time.October, 31, 23, 59, 59, 0, time.UTC),
+		End:          time.Date(2010, time.January, 1, 8, 0, 0, 0, time.UTC),
+		Description:  "new year",
+		UserID:       uuid.MustParse("9591d712-1b3e-4495-bb71-08c906273a09"),
+		WhenToNotify: time.Date(2009, time.December, 31, 23, 59, 59, 0, time.UTC),
+	}
+}
 
-	s.Equal(http.StatusOK, response.StatusCode)
+func (s *clientTestSuite) requireListed(period calendarclient.Period, title string) {
+	events, err := s.client.List(context.Background(), period, s.event.Start)
+	s.Require().NoError(err)
+
+	for _, event := range events {
+		if event.ID == s.event.ID {
+			s.Equal(title, event.Title)
+			s.Equal(s.event.Description, event.Description)
+			s.Equal(s.event.UserID, event.UserID)
+			s.True(s.event.Start.Equal(event.Start))
+			s.True(s.event.End.Equal(event.End))
+			s.True(s.event.WhenToNotify.Equal(event.WhenToNotify))
+
+			return
+		}
+	}
 
-	eventResponse, err = s.getEventResponse()
-	s.NoError(err)
+	s.Failf("event not listed", "no event %s in the %s of %s", s.event.ID, period, s.event.Start)
+}
 
-	s.Contains(s.getBody(response), eventResponse)
-	response.Body.Close()
+func (s *clientTestSuite) TestFull() {
+	ctx := context.Background()
 
-	// month
-	pathMonth := s.generatePath("month")
-	response = s.req(http.MethodGet, pathMonth, bytes.NewReader(reqBytes))
+	// create
+	_, err := s.client.Create(ctx, s.event)
+	s.Require().NoError(err)
 
-	s.Equal(http.StatusOK, response.StatusCode)
+	// double
+	_, err = s.client.Create(ctx, s.event)
+	s.ErrorIs(err, calendarclient.ErrConflict)
 
-	eventResponse, err = s.getEventResponse()
-	s.NoError(err)
+	// get
+	event, err := s.client.Get(ctx, s.event.ID)
+	s.Require().NoError(err)
+	s.Equal(s.event.Title, event.Title)
 
-	s.Contains(s.getBody(response), eventResponse)
-	response.Body.Close()
+	// day, week and month
+	s.requireListed(calendarclient.Day, "test")
+	s.requireListed(calendarclient.Week, "test")
+	s.requireListed(calendarclient.Month, "test")
 
 	// update
-	event := &internalhttp.EventRequest{
-		ID:     "14670ec6-dbca-425b-a4c7-d13c269af380",
-		Title:  "test 2",
-		Start:  "2009-10-31T23:59:59Z",
-		End:    "2010-01-01T08:00:00Z",
-		Desc:   "new year",
-		UserID: "9591d712-1b3e-4495-bb71-08c906273a09",
-		When:   "2009-12-31T23:59:59Z",
-	}
-
-	reqBytes, err = json.Marshal(event)
-	s.NoError(err)
-
-	response = s.req(http.MethodPatch, fmt.Sprintf("events/%s", s.event.ID), bytes.NewReader(reqBytes))
-
-	s.Equal(http.StatusOK, response.StatusCode)
-	s.Equal(
-		`{"Status":200,"Message":"event 14670ec6-dbca-425b-a4c7-d13c269af380 was updated"}`,
-		s.getBody(response))
-
-	response.Body.Close()
+	updated := s.event
+	updated.Title = "test 2"
+	s.Require().NoError(s.client.Update(ctx, updated))
 
 	// check updated
-	pathMonth = s.generatePath("month")
-	response = s.req(http.MethodGet, pathMonth, bytes.NewReader(reqBytes))
-
-	s.Equal(http.StatusOK, response.StatusCode)
-
-	s.Contains(s.getBody(response), `test 2`)
-	response.Body.Close()
+	s.requireListed(calendarclient.Month, "test 2")
 
 	// delete
-	response = s.req(http.MethodDelete, fmt.Sprintf("events/%s", s.event.ID), nil)
-
-	s.Equal(http.StatusOK, response.StatusCode)
-
-	expected := `{"Status":200,"Message":"event 14670ec6-dbca-425b-a4c7-d13c269af380 was deleted"}`
-	s.Equal(expected, s.getBody(response))
-	response.Body.Close()
+	s.Require().NoError(s.client.Delete(ctx, s.event.ID))
 
-	// check empty
-	pathMonth = s.generatePath("month")
-	response = s.req(http.MethodGet, pathMonth, bytes.NewReader(reqBytes))
+	// check deleted
+	_, err = s.client.Get(ctx, s.event.ID)
+	s.ErrorIs(err, calendarclient.ErrNotFound)
 
-	s.Equal(http.StatusOK, response.StatusCode)
-
-	s.Equal(`{}`, s.getBody(response))
-	response.Body.Close()
+	events, err := s.client.List(ctx, calendarclient.Month, s.event.Start)
+	s.Require().NoError(err)
+	for _, event := range events {
+		s.NotEqual(s.event.ID, event.ID)
+	}
 }